@@ -0,0 +1,198 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultAdminPageSize = 100
+	maxAdminPageSize     = 1000
+)
+
+type AdminHandler struct {
+	service     *service.ShortLinkService
+	linkService *service.DynamicLinkService
+	config      *config.Config
+}
+
+func NewAdminHandler(service *service.ShortLinkService, linkService *service.DynamicLinkService, config *config.Config) *AdminHandler {
+	return &AdminHandler{service: service, linkService: linkService, config: config}
+}
+
+// RequireToken rejects requests without "Authorization: Bearer <ADMIN_API_TOKEN>".
+func (h *AdminHandler) RequireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.AdminAPIToken)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "Missing or invalid admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *AdminHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := model.LinkFilter{
+		Domain:    query.Get("domain"),
+		Prefix:    query.Get("prefix"),
+		PageSize:  defaultAdminPageSize,
+		PageToken: query.Get("pageToken"),
+	}
+
+	if pageSize := query.Get("pageSize"); pageSize != "" {
+		size, err := strconv.Atoi(pageSize)
+		if err != nil || size <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "pageSize must be a positive integer")
+			return
+		}
+		filter.PageSize = min(size, maxAdminPageSize)
+	}
+
+	for param, target := range map[string]*time.Time{
+		"createdAfter":  &filter.CreatedAfter,
+		"createdBefore": &filter.CreatedBefore,
+	} {
+		if value := query.Get(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", param+" must be an RFC 3339 timestamp")
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	response, err := h.service.ListLinks(r.Context(), filter)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *AdminHandler) GetLink(w http.ResponseWriter, r *http.Request) {
+	link, err := h.service.GetLink(r.Context(), h.domain(r), chi.URLParam(r, "shortCode"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, link)
+}
+
+func (h *AdminHandler) PutLink(w http.ResponseWriter, r *http.Request) {
+	var req model.PutLinkRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxShortLinkRequestBytes)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload")
+		return
+	}
+
+	link, created, err := h.service.PutLink(r.Context(), h.domain(r), chi.URLParam(r, "shortCode"), &req)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, link)
+}
+
+func (h *AdminHandler) PatchLink(w http.ResponseWriter, r *http.Request) {
+	var patch model.LinkPatch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxShortLinkRequestBytes)).Decode(&patch); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload")
+		return
+	}
+
+	link, err := h.service.PatchLink(r.Context(), h.domain(r), chi.URLParam(r, "shortCode"), &patch)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, link)
+}
+
+func (h *AdminHandler) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteLink(r.Context(), h.domain(r), chi.URLParam(r, "shortCode")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportLinks ingests a CSV or JSON export of Firebase Dynamic Links from the request body.
+// A partially completed import can be resumed by sending the same file with skipRows set
+// to the lastRow of the previous report.
+func (h *AdminHandler) ImportLinks(w http.ResponseWriter, r *http.Request) {
+	options := service.ImportOptions{Format: r.URL.Query().Get("format")}
+	if skipRows := r.URL.Query().Get("skipRows"); skipRows != "" {
+		rows, err := strconv.ParseInt(skipRows, 10, 64)
+		if err != nil || rows < 0 {
+			writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "skipRows must be a non-negative integer")
+			return
+		}
+		options.SkipRows = rows
+	}
+
+	// Large exports take longer than the server's default read and write timeouts.
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	report, err := h.service.ImportLinks(r.Context(), r.Body, options)
+	if err != nil {
+		if report == nil {
+			h.writeError(w, err)
+			return
+		}
+		log.Error().Err(err).Int64("lastRow", report.LastRow).Msg("Link import stopped")
+		writeJSON(w, http.StatusInternalServerError, struct {
+			Error  string              `json:"error"`
+			Report *model.ImportReport `json:"report"`
+		}{Error: err.Error(), Report: report})
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// domain returns the link domain a request addresses: the domain query parameter, or the
+// non-preview form of the request host.
+func (h *AdminHandler) domain(r *http.Request) string {
+	if domain := r.URL.Query().Get("domain"); domain != "" {
+		return domain
+	}
+	if host, err := h.linkService.GetNonPreviewHost(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
+
+func (h *AdminHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrLinkNotFound):
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "Link not found")
+	case errors.Is(err, service.ErrLinkExists):
+		writeAPIError(w, http.StatusConflict, "ABORTED", "Link was changed concurrently")
+	case errors.Is(err, service.ErrInvalidShortLinkRequest):
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+	default:
+		log.Error().Err(err).Msg("Admin request failed")
+		writeAPIError(w, http.StatusInternalServerError, "INTERNAL", "Internal Server Error")
+	}
+}
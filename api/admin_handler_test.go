@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+)
+
+func TestAdminLinks(t *testing.T) {
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated", AdminAPIToken: "admin-secret"}
+	store := service.NewMemoryLinkStore()
+	resolver := service.NewCachingLinkResolver(service.NewStoreLinkResolver(store), service.CacheOptions{Size: 10, TTL: time.Hour})
+	router := newTestRouterWithResolver(cfg, resolver, store)
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("User-Agent", testDesktopUA)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "https://example.page.link/admin/links/abc", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	putBody := `{"longLink": "https://example.page.link/?link=https%3A%2F%2Fexample.com&ofl=https%3A%2F%2Fexample.com%2Fold"}`
+	if rec := do(http.MethodPut, "https://example.page.link/admin/links/abc", putBody, "admin-secret"); rec.Code != http.StatusCreated {
+		t.Fatalf("PUT status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	if rec := do(http.MethodPut, "https://example.page.link/admin/links/abd", putBody, "admin-secret"); rec.Code != http.StatusCreated {
+		t.Fatalf("PUT status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if rec := do(http.MethodPut, "https://example.page.link/admin/links/xyz?domain=other.page.link", putBody, "admin-secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT with mismatched domain status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	// Warm the cache so the update below has something to invalidate.
+	if rec := do(http.MethodGet, "https://example.page.link/abc", "", ""); rec.Header().Get("Location") != "https://example.com/old" {
+		t.Fatalf("Location = %v, want old ofl", rec.Header().Get("Location"))
+	}
+
+	patchBody := `{"ofl": "https://example.com/new", "st": "Campaign"}`
+	rec := do(http.MethodPatch, "https://example.page.link/admin/links/abc", patchBody, "admin-secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var patched model.DynamicLink
+	if err := json.NewDecoder(rec.Body).Decode(&patched); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if patched.ShortCode != "abc" || !strings.Contains(patched.LongLink, "st=Campaign") {
+		t.Errorf("PATCH response = %+v, want updated long link for abc", patched)
+	}
+
+	if rec := do(http.MethodGet, "https://example.page.link/abc", "", ""); rec.Header().Get("Location") != "https://example.com/new" {
+		t.Errorf("Location after PATCH = %v, want new ofl", rec.Header().Get("Location"))
+	}
+
+	if rec := do(http.MethodPatch, "https://example.page.link/admin/links/abd", `{"notBefore": "2030-01-01T00:00:00Z", "expiresAt": "2029-01-01T00:00:00Z"}`, "admin-secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH with inverted window status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := do(http.MethodPatch, "https://example.page.link/admin/links/abd", `{"expiresAt": "2020-01-01T00:00:00Z"}`, "admin-secret"); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH expiresAt status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := do(http.MethodGet, "https://example.page.link/abd", "", ""); rec.Code != http.StatusGone {
+		t.Errorf("redirect after expiry status = %d, want %d", rec.Code, http.StatusGone)
+	}
+
+	rec = do(http.MethodGet, "https://example.page.link/admin/links?prefix=ab&pageSize=1", "", "admin-secret")
+	var page model.ListLinksResponse
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Links) != 1 || page.Links[0].ShortCode != "abc" || page.NextPageToken == "" {
+		t.Fatalf("first page = %+v, want abc and a next page token", page)
+	}
+
+	rec = do(http.MethodGet, "https://example.page.link/admin/links?prefix=ab&pageSize=1&pageToken="+page.NextPageToken, "", "admin-secret")
+	page = model.ListLinksResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Links) != 1 || page.Links[0].ShortCode != "abd" || page.NextPageToken != "" {
+		t.Errorf("second page = %+v, want abd and no next page token", page)
+	}
+
+	if rec := do(http.MethodGet, "https://example.page.link/admin/links?pageToken=%25%25%25", "", "admin-secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed page token status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if rec := do(http.MethodDelete, "https://example.page.link/admin/links/abc", "", "admin-secret"); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := do(http.MethodGet, "https://example.page.link/abc", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("redirect after DELETE status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := do(http.MethodGet, "https://example.page.link/admin/links/abc", "", "admin-secret"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET after DELETE status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
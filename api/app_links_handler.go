@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+
+	"dynamic-link-redirect/api/service"
+
+	"github.com/rs/zerolog/log"
+)
+
+type AppLinksHandler struct {
+	appLinks    *service.AppLinks
+	linkService *service.DynamicLinkService
+}
+
+// NewAppLinksHandler creates the handler of the .well-known documents. appLinks may be nil to
+// serve only the override files.
+func NewAppLinksHandler(appLinks *service.AppLinks, linkService *service.DynamicLinkService) *AppLinksHandler {
+	return &AppLinksHandler{appLinks: appLinks, linkService: linkService}
+}
+
+// AppleAppSiteAssociation serves /.well-known/apple-app-site-association for the requested host.
+func (h *AppLinksHandler) AppleAppSiteAssociation(w http.ResponseWriter, r *http.Request) {
+	// Apple's CDN caches the document; clients should not cache it on top of that.
+	w.Header().Set("Expires", "0")
+
+	tenant := h.linkService.Tenant(r.Host)
+	var document []byte
+	if h.appLinks != nil {
+		document = h.appLinks.AppleAppSiteAssociation(r.Host)
+	}
+	serveAppLinksDocument(w, r, tenant.AppleAppSiteAssociationFile, document)
+}
+
+// AssetLinks serves /.well-known/assetlinks.json for the requested host.
+func (h *AppLinksHandler) AssetLinks(w http.ResponseWriter, r *http.Request) {
+	tenant := h.linkService.Tenant(r.Host)
+	var document []byte
+	if h.appLinks != nil {
+		document = h.appLinks.AssetLinks(r.Host)
+	}
+	serveAppLinksDocument(w, r, tenant.AssetLinksFile, document)
+}
+
+// serveAppLinksDocument serves overrideFile if set, else the generated document, else 404.
+func serveAppLinksDocument(w http.ResponseWriter, r *http.Request, overrideFile string, document []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	if overrideFile != "" {
+		http.ServeFile(w, r, overrideFile)
+		return
+	}
+	if document == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := w.Write(document); err != nil {
+		log.Error().Err(err).Msg("Failed to write app links document")
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+)
+
+func TestAppLinksDocuments(t *testing.T) {
+	dir := t.TempDir()
+	override := filepath.Join(dir, "apple-app-site-association.json")
+	if err := os.WriteFile(override, []byte(`{"applinks":{"details":[{"appIDs":["ZYXWV98765.com.partner"],"components":[{"/":"/*"}]}]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		PreviewUrlStyle: "hyphenated",
+		AppleTeamID:     "ABCDE12345",
+		AppleBundleIDs:  "com.example.app",
+		TenantsFile:     filepath.Join(dir, "tenants.json"),
+	}
+	if err := os.WriteFile(cfg.TenantsFile, []byte(`[{"name": "partner", "hosts": ["partner.page.link"], "appLinks": {}, "appleAppSiteAssociationFile": "`+override+`"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appLinks, err := service.NewAppLinks(tenants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linkService := service.NewDynamicLinkService(cfg, tenants, service.NewMemoryLinkResolver(nil))
+	shortLinkService := service.NewShortLinkService(cfg, service.NewMemoryLinkStore(), linkService)
+	router := NewRouter(cfg, linkService, shortLinkService, RouterOptions{AppLinks: appLinks})
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "generated apple-app-site-association",
+			method:         http.MethodGet,
+			target:         "https://example.page.link/.well-known/apple-app-site-association",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"applinks":{"apps":[],"details":[{"appIDs":["ABCDE12345.com.example.app"],"components":[{"/":"/*"}]},{"appID":"ABCDE12345.com.example.app","paths":["/*"]}]}}`,
+		},
+		{
+			name:           "generated document on the preview host",
+			method:         http.MethodHead,
+			target:         "https://preview-example.page.link/.well-known/apple-app-site-association",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "override file",
+			method:         http.MethodGet,
+			target:         "https://partner.page.link/.well-known/apple-app-site-association",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"applinks":{"details":[{"appIDs":["ZYXWV98765.com.partner"],"components":[{"/":"/*"}]}]}}`,
+		},
+		{
+			name:           "no Android app configured",
+			method:         http.MethodGet,
+			target:         "https://example.page.link/.well-known/assetlinks.json",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus == http.StatusOK && rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", rec.Header().Get("Content-Type"))
+			}
+			if tt.expectedBody != "" && rec.Body.String() != tt.expectedBody {
+				t.Errorf("body = %s, want %s", rec.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/utils"
+)
+
+type clickEventKey struct{}
+
+// newClickEvent starts the event for a redirect request. It is attached to the request so the
+// step that answers the request can record its decision.
+func (h *DynamicLinkHandler) newClickEvent(r *http.Request) (*http.Request, *model.ClickEvent) {
+	event := &model.ClickEvent{
+		Timestamp: time.Now().UTC(),
+		Domain:    r.Host,
+		ShortCode: strings.Trim(r.URL.Path, "/"),
+		Referrer:  r.Referer(),
+	}
+	if h.config.CountryHeader != "" {
+		event.Country = strings.ToUpper(strings.TrimSpace(r.Header.Get(h.config.CountryHeader)))
+	}
+	return r.WithContext(context.WithValue(r.Context(), clickEventKey{}, event)), event
+}
+
+// emitClickEvent hands the finished event to the pipeline. Requests that never reached a
+// decision, such as ones that panicked, are not emitted.
+func (h *DynamicLinkHandler) emitClickEvent(event *model.ClickEvent) {
+	if h.events == nil || event.Decision == "" {
+		return
+	}
+	h.events.Emit(*event)
+}
+
+// describeClickEvent adds what the resolved link and the classified device tell about the click.
+func describeClickEvent(event *model.ClickEvent, queryParams url.Values, device utils.Device) {
+	event.Platform = clickEventPlatform(device)
+	event.InAppBrowser = device.InAppBrowser
+	event.UTMSource = queryParams.Get("utm_source")
+	event.UTMMedium = queryParams.Get("utm_medium")
+	event.UTMCampaign = queryParams.Get("utm_campaign")
+	event.UTMTerm = queryParams.Get("utm_term")
+	event.UTMContent = queryParams.Get("utm_content")
+}
+
+func clickEventPlatform(device utils.Device) string {
+	switch {
+	case device.IsBot():
+		return "bot"
+	case device.IsIOS():
+		return "ios"
+	case device.IsAndroid():
+		return "android"
+	}
+	return "web"
+}
+
+// recordDecision notes how the redirect flow answered the request and where it sent the client.
+func recordDecision(r *http.Request, decision, target string) {
+	if event, ok := r.Context().Value(clickEventKey{}).(*model.ClickEvent); ok {
+		event.Decision = decision
+		event.TargetURL = target
+		if decision == model.DecisionPreviewPage {
+			event.PreviewShown = true
+		}
+	}
+}
+
+// emitAppEvent records an app launch attributed to a short link. Unmatched launches are not
+// emitted since they belong to no link.
+func emitAppEvent(events *service.ClickEventPipeline, decision, platform string, response *model.InstallAttributionResponse) {
+	if events == nil || response.MatchType == model.MatchNone {
+		return
+	}
+	shortLink, err := url.Parse(response.RequestedLink)
+	if err != nil || shortLink.Host == "" {
+		return
+	}
+	events.Emit(model.ClickEvent{
+		Timestamp:   time.Now().UTC(),
+		Domain:      shortLink.Host,
+		ShortCode:   strings.Trim(shortLink.Path, "/"),
+		Platform:    platform,
+		Decision:    decision,
+		TargetURL:   response.DeepLink,
+		UTMSource:   response.UTMSource,
+		UTMMedium:   response.UTMMedium,
+		UTMCampaign: response.UTMCampaign,
+	})
+}
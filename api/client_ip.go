@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// parseTrustedProxies reads TRUSTED_PROXIES: comma separated CIDRs or addresses of the proxies
+// whose forwarding headers are believed. Invalid entries are logged and skipped.
+func parseTrustedProxies(value string) []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Error().Str("value", entry).Msg("Invalid TRUSTED_PROXIES entry, ignoring it")
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+func isTrustedProxy(ip net.IP, proxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// realIP sets RemoteAddr to the client address from X-Forwarded-For or X-Real-IP, but only for
+// requests that come from a trusted proxy; anyone else could forge the headers. The client is the
+// rightmost X-Forwarded-For address that is not a trusted proxy itself.
+func realIP(proxies []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if client := forwardedClientIP(r, proxies); client != "" {
+				r.RemoteAddr = client
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forwardedClientIP(r *http.Request, proxies []*net.IPNet) string {
+	if len(proxies) == 0 || !isTrustedProxy(net.ParseIP(clientIP(r)), proxies) {
+		return ""
+	}
+
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return ""
+			}
+			if i == 0 || !isTrustedProxy(ip, proxies) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
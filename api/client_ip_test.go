@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	proxies := parseTrustedProxies("10.0.0.0/8, 192.0.2.1, not-an-ip")
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		expectedAddr string
+	}{
+		{name: "direct client forging headers", remoteAddr: "203.0.113.7:5000", forwardedFor: "198.51.100.1", realIP: "198.51.100.2", expectedAddr: "203.0.113.7:5000"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:5000", forwardedFor: "198.51.100.1", expectedAddr: "198.51.100.1"},
+		{name: "client prepending a forged hop", remoteAddr: "10.1.2.3:5000", forwardedFor: "198.51.100.9, 203.0.113.7, 192.0.2.1", expectedAddr: "203.0.113.7"},
+		{name: "trusted proxy with X-Real-IP", remoteAddr: "192.0.2.1:5000", realIP: "198.51.100.2", expectedAddr: "198.51.100.2"},
+		{name: "garbage header", remoteAddr: "10.1.2.3:5000", forwardedFor: "unknown", expectedAddr: "10.1.2.3:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := realIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.expectedAddr {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.expectedAddr)
+			}
+		})
+	}
+}
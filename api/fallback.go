@@ -0,0 +1,124 @@
+package api
+
+import (
+	"expvar"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultFallbacks counts, per platform, the redirects that found no usable link parameter and
+// ended on the configured default page.
+var defaultFallbacks = expvar.NewMap("redirectDefaultFallbacks")
+
+// scriptSchemes run in the page that navigates to them rather than leaving it.
+var scriptSchemes = map[string]bool{"javascript": true, "vbscript": true, "data": true}
+
+// redirectTarget returns the first of the named parameters that holds an absolute URL and its
+// name, or "". Invalid values are logged and skipped so the next step of the fallback chain can
+// apply.
+func redirectTarget(queryParams url.Values, paramNames ...string) (string, string) {
+	for _, paramName := range paramNames {
+		link := queryParams.Get(paramName)
+		if link == "" {
+			continue
+		}
+
+		unescapedLink, err := url.QueryUnescape(link)
+		if err != nil {
+			log.Error().Str(paramName, link).Msgf("Failed to unescape '%s' parameter", paramName)
+			continue
+		}
+
+		parsedURL, err := url.Parse(unescapedLink)
+		if err != nil || !parsedURL.IsAbs() {
+			log.Error().Str(paramName, unescapedLink).Msgf("Invalid '%s' link", paramName)
+			continue
+		}
+		// The escape page hands the target to script, where html/template does not sanitize it.
+		if scriptSchemes[strings.ToLower(parsedURL.Scheme)] {
+			log.Warn().Str(paramName, unescapedLink).Msgf("Refusing script '%s' link", paramName)
+			continue
+		}
+		return unescapedLink, paramName
+	}
+	return "", ""
+}
+
+// fallbackTarget is the end of every platform's chain once the platform fallback and store steps
+// did not apply: ofl, then link.
+func fallbackTarget(queryParams url.Values) (string, int, string) {
+	target, paramName := redirectTarget(queryParams, "ofl", "link")
+	return target, http.StatusFound, paramName
+}
+
+// redirectOrDefault redirects to target, or when the chain found none, to DEFAULT_REDIRECT_URL or
+// the built-in default page. decision is recorded on the click event when target is used.
+func (h *DynamicLinkHandler) redirectOrDefault(w http.ResponseWriter, r *http.Request, target string, status int, decision, platform string) {
+	if target != "" {
+		recordDecision(r, decision, target)
+		http.Redirect(w, r, target, status)
+		return
+	}
+
+	defaultFallbacks.Add(platform, 1)
+	log.Warn().
+		Str("platform", platform).
+		Str("host", r.Host).
+		Str("path", r.URL.Path).
+		Msg("Dynamic link has no usable redirect target, serving default page")
+
+	if target := h.defaultRedirectURL(); target != "" {
+		recordDecision(r, model.DecisionDefaultRedirect, target)
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+
+	recordDecision(r, model.DecisionDefaultPage, "")
+	span := traceStage(r, "render default.html")
+	defer span.End()
+	tenant := h.service.Tenant(r.Host)
+	handleDefaultPage(w, tenant.AppName, tenant.AppIconImageURL)
+}
+
+// defaultRedirectURL returns DEFAULT_REDIRECT_URL if it is set to an absolute URL.
+func (h *DynamicLinkHandler) defaultRedirectURL() string {
+	target := h.config.DefaultRedirectURL
+	if target == "" {
+		return ""
+	}
+	if parsedURL, err := url.Parse(target); err != nil || !parsedURL.IsAbs() {
+		log.Error().Str("target", target).Msg("Invalid default redirect URL")
+		return ""
+	}
+	return target
+}
+
+func handleDefaultPage(w http.ResponseWriter, appName, appIconImageURL string) {
+	tmpl, err := template.ParseFiles("templates/default.html")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse default.html template")
+		metrics.TemplateErrors.WithLabelValues("default.html").Inc()
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = tmpl.Execute(w, struct {
+		AppName         string
+		AppIconImageURL string
+	}{
+		AppName:         appName,
+		AppIconImageURL: appIconImageURL,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute template")
+		metrics.TemplateErrors.WithLabelValues("default.html").Inc()
+	}
+}
@@ -1,73 +1,139 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"dynamic-link-redirect/api/model"
 	"dynamic-link-redirect/api/service"
 	"dynamic-link-redirect/config"
+	"dynamic-link-redirect/metrics"
 	"dynamic-link-redirect/utils"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog/log"
 )
 
 type DynamicLinkHandler struct {
-	service *service.DynamicLinkService
-	config  *config.Config
+	service        *service.DynamicLinkService
+	attribution    *service.InstallAttributionService
+	events         *service.ClickEventPipeline
+	config         *config.Config
+	escapeFamilies map[string]bool
+	escapeTimeouts escapeTimeouts
 }
 
-func NewDynamicLinkHandler(service *service.DynamicLinkService, config *config.Config) *DynamicLinkHandler {
-	return &DynamicLinkHandler{service: service, config: config}
+// NewDynamicLinkHandler creates the redirect handler. attribution may be nil to disable click
+// recording and events nil to disable click events.
+func NewDynamicLinkHandler(service *service.DynamicLinkService, attribution *service.InstallAttributionService, events *service.ClickEventPipeline, config *config.Config) *DynamicLinkHandler {
+	return &DynamicLinkHandler{
+		service:        service,
+		attribution:    attribution,
+		events:         events,
+		config:         config,
+		escapeFamilies: parseEscapeFamilies(config.InAppBrowserEscape),
+		escapeTimeouts: parseEscapeTimeouts(config.InAppBrowserEscapeTimeout),
+	}
 }
 
 func (h *DynamicLinkHandler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	w = ww
+	r, event := h.newClickEvent(r)
+	defer h.emitClickEvent(event)
+	defer observeRedirect(r.Context(), event, ww, start)
+
 	requestedURL := utils.FullRequestURL(r)
 	nonPreviewHost, err := h.service.GetNonPreviewHost(r.Host)
 	if err != nil {
+		recordDecision(r, model.DecisionNotFound, "")
 		http.NotFound(w, r)
 		return
 	}
 
 	requestedURL.Host = nonPreviewHost
+	event.Domain = nonPreviewHost
 
-	dynamicLinkQueryParams, err := h.service.GetQueryParamsFromURL(r.Context(), requestedURL)
+	dynamicLinkQueryParams, linkStatus, err := h.service.ResolveLink(r.Context(), requestedURL)
 	log.Debug().Str("dynamicLinkQueryParams", dynamicLinkQueryParams.Encode()).Msg("Dynamic link query params")
 
-	if err != nil || dynamicLinkQueryParams == nil {
+	if err != nil {
+		handleResolveError(w, r, err)
+		return
+	}
+
+	if dynamicLinkQueryParams == nil || linkStatus == service.LinkNotYetActive {
+		recordDecision(r, model.DecisionNotFound, "")
 		http.NotFound(w, r)
 		return
 	}
 
+	span := traceStage(r, "ClassifyRequest")
+	device := utils.ClassifyRequest(r)
+	span.End()
+	describeClickEvent(event, dynamicLinkQueryParams, device)
+
+	if linkStatus == service.LinkExpired {
+		h.handleExpiredLink(w, r, dynamicLinkQueryParams)
+		return
+	}
+
 	requestQueryParams := r.URL.Query()
 	log.Debug().Str("request_query_params", requestQueryParams.Encode()).Msg("request query params")
-
+	span = traceStage(r, "IsPreviewHost")
 	isPreview, err := h.service.IsPreviewHost(r)
+	span.End()
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to check if host is preview")
+		recordDecision(r, model.DecisionError, "")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	userAgent := r.Header.Get("User-Agent")
-	isiPad := strings.Contains(userAgent, "iPad")
-	isiPhone := strings.Contains(userAgent, "iPhone")
-	isIos := isiPad || isiPhone
-	isAndroid := strings.Contains(userAgent, "Android")
+	if device.IsBot() {
+		// Crawlers unfurling the link get its social metadata instead of the destination's.
+		recordDecision(r, model.DecisionSocialPage, "")
+		span := traceStage(r, "render social.html")
+		defer span.End()
+		h.handleSocialPage(w, requestedURL, dynamicLinkQueryParams)
+		return
+	}
+	if device.MaybeIPad && hasIOSTarget(dynamicLinkQueryParams) {
+		recordDecision(r, model.DecisionPlatformDetection, "")
+		span := traceStage(r, "render detect.html")
+		defer span.End()
+		handlePlatformDetectionPage(w, r)
+		return
+	}
+
+	isiPad := device.IsIPad()
+	isIos := device.IsIOS()
+	isAndroid := device.IsAndroid()
 
 	// Check if fallback parameters are present for the current platform - skip preview if so
 	hasAFL := dynamicLinkQueryParams.Get("afl") != ""
-	hasIFL := dynamicLinkQueryParams.Get("ifl") != ""
-	hasIPFL := dynamicLinkQueryParams.Get("ipfl") != ""
-	skipPreview := (hasAFL && isAndroid) || (hasIFL && isiPhone) || (hasIPFL && isiPad)
+	hasIOSFallback := iosFallbackParam(dynamicLinkQueryParams, isiPad) != ""
+	// In-app browsers that get the escape interstitial skip the preview page, which it replaces.
+	inAppEscape := h.escapesInAppBrowser(device) &&
+		((isIos && iosAppURL(dynamicLinkQueryParams, isiPad) != "") || (isAndroid && androidAppURL(dynamicLinkQueryParams, "") != ""))
+	skipPreview := forcedRedirect(dynamicLinkQueryParams) || (hasAFL && isAndroid) || (hasIOSFallback && isIos) || inAppEscape
+	// The preview page counts the click. Mobile clicks that are not skipped only reach the
+	// destination through it; desktop browsers only see it on the preview host, whose button
+	// comes back with from-preview.
+	event.PreviewShown = !skipPreview && (isIos || isAndroid || requestQueryParams.Get("from-preview") == "true")
 
 	if isPreview && requestQueryParams.Get("from-preview") != "true" && !skipPreview {
 		urlCopy := utils.FullRequestURL(r)
 		newHost, err := h.service.GetNonPreviewHost(urlCopy.Host)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to get non preview host")
+			recordDecision(r, model.DecisionError, "")
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -79,7 +145,11 @@ func (h *DynamicLinkHandler) HandleRedirect(w http.ResponseWriter, r *http.Reque
 		urlCopy.RawQuery = query.Encode()
 
 		log.Debug().Str("urlCopy", urlCopy.String()).Msg("Handling preview page")
-		handlePreviewPage(w, h.config.AppIconImageURL, h.config.AppName, *urlCopy, dynamicLinkQueryParams.Get("st"), dynamicLinkQueryParams.Get("sd"), dynamicLinkQueryParams.Get("si"))
+		recordDecision(r, model.DecisionPreviewPage, urlCopy.String())
+		span := traceStage(r, "render preview.html")
+		defer span.End()
+		tenant := h.service.Tenant(r.Host)
+		handlePreviewPage(w, tenant.AppIconImageURL, tenant.AppName, *urlCopy, h.clipboardText(requestedURL, device), dynamicLinkQueryParams.Get("st"), dynamicLinkQueryParams.Get("sd"), dynamicLinkQueryParams.Get("si"))
 		return
 	}
 
@@ -90,13 +160,15 @@ func (h *DynamicLinkHandler) HandleRedirect(w http.ResponseWriter, r *http.Reque
 			previewURL, err := h.service.GeneratePreviewURL(fullURL)
 			if err != nil {
 				log.Error().Err(err).Msg("Failed to transform to preview URL")
+				recordDecision(r, model.DecisionError, "")
 				http.Error(w, "Invalid long link format", http.StatusInternalServerError)
 				return
 			}
 			log.Debug().Str("previewURL", previewURL.String()).Msg("Redirecting to preview URL")
+			recordDecision(r, model.DecisionPreviewRedirect, previewURL.String())
 			http.Redirect(w, r, previewURL.String(), http.StatusFound)
 		} else {
-			handleiOSDynamicLink(w, r, dynamicLinkQueryParams, isiPad, isiPhone)
+			h.handleiOSDynamicLink(w, r, dynamicLinkQueryParams, requestedURL, device)
 		}
 		return
 	} else if isAndroid {
@@ -106,66 +178,203 @@ func (h *DynamicLinkHandler) HandleRedirect(w http.ResponseWriter, r *http.Reque
 			previewURL, err := h.service.GeneratePreviewURL(fullURL)
 			if err != nil {
 				log.Error().Err(err).Msg("Failed to transform to preview URL")
+				recordDecision(r, model.DecisionError, "")
 				http.Error(w, "Invalid long link format", http.StatusInternalServerError)
 				return
 			}
 			log.Debug().Str("previewURL", previewURL.String()).Msg("Redirecting to preview URL")
+			recordDecision(r, model.DecisionPreviewRedirect, previewURL.String())
 			http.Redirect(w, r, previewURL.String(), http.StatusFound)
 		} else {
-			handleAndroidDynamicLink(w, r, dynamicLinkQueryParams, requestedURL)
+			h.handleAndroidDynamicLink(w, r, dynamicLinkQueryParams, requestedURL, device)
 		}
 		return
 	} else {
-		handleWebUserAgent(w, r, dynamicLinkQueryParams)
+		h.handleWebUserAgent(w, r, dynamicLinkQueryParams)
 		return
 	}
 }
 
-func handleWebUserAgent(w http.ResponseWriter, r *http.Request, queryParams url.Values) {
-	log.Debug().Msg("Handling web user agent")
-	ofl := queryParams.Get("ofl")
-	if ofl != "" {
-		unescapedOfl, err := url.QueryUnescape(ofl)
-		if err != nil {
-			log.Error().Str("ofl", ofl).Msg("Failed to unescape 'ofl' parameter")
-			http.Error(w, "Invalid 'ofl' link format", http.StatusInternalServerError)
-			return
+func handleResolveError(w http.ResponseWriter, r *http.Request, err error) {
+	var exchangeErr *service.ExchangeError
+	switch {
+	case errors.Is(err, service.ErrExchangeUnavailable):
+		log.Warn().Err(err).Msg("Exchange backend unavailable")
+		recordDecision(r, model.DecisionUnavailable, "")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	case errors.As(err, &exchangeErr):
+		log.Error().Err(err).Msg("Exchange backend failed")
+		recordDecision(r, model.DecisionError, "")
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+	default:
+		log.Error().Err(err).Msg("Failed to resolve dynamic link")
+		recordDecision(r, model.DecisionNotFound, "")
+		http.NotFound(w, r)
+	}
+}
+
+// handleExpiredLink serves a link past its expiresAt according to EXPIRED_LINK_BEHAVIOR.
+func (h *DynamicLinkHandler) handleExpiredLink(w http.ResponseWriter, r *http.Request, queryParams url.Values) {
+	log.Debug().Str("behavior", h.config.ExpiredLinkBehavior).Msg("Handling expired dynamic link")
+
+	target := ""
+	switch h.config.ExpiredLinkBehavior {
+	case "fallback":
+		target = h.config.ExpiredLinkFallbackURL
+	case "ofl":
+		target, _ = redirectTarget(queryParams, "ofl")
+		if target == "" {
+			target = h.config.ExpiredLinkFallbackURL
 		}
-		parsedOfl, err := url.Parse(unescapedOfl)
-		if err != nil || !parsedOfl.IsAbs() {
-			log.Error().Str("ofl", unescapedOfl).Msg("Invalid 'ofl' parameter")
-			http.Error(w, "Invalid 'ofl' link format", http.StatusInternalServerError)
+	}
+
+	if target != "" {
+		if parsedURL, err := url.Parse(target); err == nil && parsedURL.IsAbs() {
+			recordDecision(r, model.DecisionExpiredRedirect, target)
+			http.Redirect(w, r, target, http.StatusFound)
 			return
 		}
-		http.Redirect(w, r, unescapedOfl, http.StatusFound)
+		log.Error().Str("target", target).Msg("Invalid expired link redirect target")
+	}
+
+	recordDecision(r, model.DecisionGonePage, "")
+	span := traceStage(r, "render gone.html")
+	defer span.End()
+	tenant := h.service.Tenant(r.Host)
+	handleGonePage(w, tenant.AppName, tenant.AppIconImageURL)
+}
+
+func handleGonePage(w http.ResponseWriter, appName, appIconImageURL string) {
+	tmpl, err := template.ParseFiles("templates/gone.html")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse gone.html template")
+		metrics.TemplateErrors.WithLabelValues("gone.html").Inc()
+		http.Error(w, "Gone", http.StatusGone)
 		return
 	}
 
-	link := queryParams.Get("link")
-	if link != "" {
-		unescapedLink, err := url.QueryUnescape(link)
-		if err != nil {
-			log.Error().Str("link", link).Msg("Failed to unescape 'link' parameter")
-			http.Error(w, "Invalid 'link' link format", http.StatusInternalServerError)
-			return
-		}
-		parsedOfl, err := url.Parse(unescapedLink)
-		if err != nil || !parsedOfl.IsAbs() {
-			log.Error().Str("link", unescapedLink).Msg("Invalid 'link' parameter")
-			http.Error(w, "Invalid 'link' link format", http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, unescapedLink, http.StatusFound)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	err = tmpl.Execute(w, struct {
+		AppName         string
+		AppIconImageURL string
+	}{
+		AppName:         appName,
+		AppIconImageURL: appIconImageURL,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute template")
+		metrics.TemplateErrors.WithLabelValues("gone.html").Inc()
+	}
+}
+
+// canonicalDynamicLink strips the parameters this service adds while routing a request.
+func canonicalDynamicLink(dynamicLink *url.URL) *url.URL {
+	canonical := *dynamicLink
+	query := canonical.Query()
+	query.Del("from-preview")
+	query.Del(utils.PlatformHintParam)
+	query.Del(screenParam)
+	canonical.RawQuery = query.Encode()
+	canonical.Fragment = ""
+	return &canonical
+}
+
+// clipboardText returns the signed token the preview page copies for iOS users when clipboard
+// handoff is enabled, or "" to copy the link itself.
+func (h *DynamicLinkHandler) clipboardText(dynamicLink *url.URL, device utils.Device) string {
+	if h.attribution == nil || !h.attribution.ClipboardHandoffEnabled() || !device.IsIOS() {
+		return ""
+	}
+	token, err := h.attribution.IssueClipboardToken(canonicalDynamicLink(dynamicLink).String())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to issue clipboard token")
+		return ""
+	}
+	return token
+}
+
+// handleSocialPage serves the link's Open Graph and Twitter card tags on both the preview and
+// the non-preview host.
+func (h *DynamicLinkHandler) handleSocialPage(w http.ResponseWriter, dynamicLink *url.URL, queryParams url.Values) {
+	log.Debug().Str("dynamicLink", dynamicLink.String()).Msg("Serving social metadata to crawler")
+
+	canonical := canonicalDynamicLink(dynamicLink)
+
+	tmpl, err := template.ParseFiles("templates/social.html")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse social.html template")
+		metrics.TemplateErrors.WithLabelValues("social.html").Inc()
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	http.NotFound(w, r)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = tmpl.Execute(w, struct {
+		DynamicLink       string
+		AppName           string
+		SocialTitle       string
+		SocialDescription string
+		SocialImageLink   string
+	}{
+		DynamicLink:       canonical.String(),
+		AppName:           h.service.Tenant(dynamicLink.Host).AppName,
+		SocialTitle:       queryParams.Get("st"),
+		SocialDescription: queryParams.Get("sd"),
+		SocialImageLink:   queryParams.Get("si"),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute template")
+		metrics.TemplateErrors.WithLabelValues("social.html").Inc()
+	}
+}
+
+// handlePlatformDetectionPage asks the browser whether it is an iPad requesting the desktop site
+// and reloads the link with the answer as a platform hint.
+func handlePlatformDetectionPage(w http.ResponseWriter, r *http.Request) {
+	hintURL := func(hint string) string {
+		u := utils.FullRequestURL(r)
+		query := u.Query()
+		query.Set(utils.PlatformHintParam, hint)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	tmpl, err := template.ParseFiles("templates/detect.html")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse detect.html template")
+		metrics.TemplateErrors.WithLabelValues("detect.html").Inc()
+		http.Redirect(w, r, hintURL("macos"), http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err = tmpl.Execute(w, struct {
+		IPadURL    string
+		DesktopURL string
+	}{
+		IPadURL:    hintURL("ipados"),
+		DesktopURL: hintURL("macos"),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute template")
+		metrics.TemplateErrors.WithLabelValues("detect.html").Inc()
+	}
 }
 
-func handlePreviewPage(w http.ResponseWriter, appIconImageURL, appName string, dynamicLink url.URL, socialTitle, socialDescription, socialImageLink string) {
+func (h *DynamicLinkHandler) handleWebUserAgent(w http.ResponseWriter, r *http.Request, queryParams url.Values) {
+	log.Debug().Msg("Handling web user agent")
+	target, status, decision := fallbackTarget(queryParams)
+	h.redirectOrDefault(w, r, target, status, decision, "web")
+}
+
+func handlePreviewPage(w http.ResponseWriter, appIconImageURL, appName string, dynamicLink url.URL, clipboardText, socialTitle, socialDescription, socialImageLink string) {
 	log.Debug().Msg("Handling preview page")
 	tmpl, err := template.ParseFiles("templates/preview.html")
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to parse preview.html template")
+		metrics.TemplateErrors.WithLabelValues("preview.html").Inc()
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
@@ -174,6 +383,7 @@ func handlePreviewPage(w http.ResponseWriter, appIconImageURL, appName string, d
 
 	err = tmpl.Execute(w, struct {
 		DynamicLink       string
+		ClipboardText     string
 		AppIconImageURL   string
 		AppName           string
 		SocialTitle       string
@@ -181,6 +391,7 @@ func handlePreviewPage(w http.ResponseWriter, appIconImageURL, appName string, d
 		SocialImageLink   string
 	}{
 		DynamicLink:       dynamicLink.String(),
+		ClipboardText:     clipboardText,
 		AppIconImageURL:   appIconImageURL,
 		AppName:           appName,
 		SocialTitle:       socialTitle,
@@ -189,40 +400,36 @@ func handlePreviewPage(w http.ResponseWriter, appIconImageURL, appName string, d
 	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to execute template")
+		metrics.TemplateErrors.WithLabelValues("preview.html").Inc()
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 }
 
-func handleiOSDynamicLink(w http.ResponseWriter, r *http.Request, queryParams url.Values, isiPad bool, isiPhone bool) {
+func (h *DynamicLinkHandler) handleiOSDynamicLink(w http.ResponseWriter, r *http.Request, queryParams url.Values, dynamicLink *url.URL, device utils.Device) {
 	log.Debug().Msg("Handling iOS dynamic link")
 
-	redirectIfValid := func(paramName string, deviceCheck bool) bool {
-		link := queryParams.Get(paramName)
-		if link == "" || !deviceCheck {
-			return false
-		}
-
-		unescapedLink, err := url.QueryUnescape(link)
-		if err != nil {
-			log.Error().Str(paramName, link).Msgf("Failed to unescape '%s' parameter", paramName)
-			http.Error(w, "Invalid '"+paramName+"' link format", http.StatusInternalServerError)
-			return true
-		}
-
-		parsedURL, err := url.Parse(unescapedLink)
-		if err != nil || !parsedURL.IsAbs() {
-			log.Error().Str(paramName, unescapedLink).Msgf("Invalid '%s' link", paramName)
-			http.Error(w, "Invalid '"+paramName+"' link format", http.StatusInternalServerError)
-			return true
+	target, status, decision := h.iosTarget(r, queryParams, dynamicLink, device)
+	if h.escapesInAppBrowser(device) {
+		if appURL := iosAppURL(queryParams, device.IsIPad()); appURL != "" {
+			recordDecision(r, model.DecisionEscapePage, appURL)
+			span := traceStage(r, "render escape.html")
+			defer span.End()
+			h.handleEscapePage(w, r, device, appURL, target)
+			return
 		}
-
-		http.Redirect(w, r, unescapedLink, http.StatusFound)
-		return true
 	}
+	h.redirectOrDefault(w, r, target, status, decision, "ios")
+}
 
-	if redirectIfValid("ipfl", isiPad) || redirectIfValid("ifl", isiPhone) {
-		return
+// iosTarget returns where an iOS device is sent when the app does not open the link, with the
+// redirect status and the decision taken, or "" when none of the link's parameters applies. App
+// Store redirects are recorded for install attribution.
+func (h *DynamicLinkHandler) iosTarget(r *http.Request, queryParams url.Values, dynamicLink *url.URL, device utils.Device) (string, int, string) {
+	if fallbackParam := iosFallbackParam(queryParams, device.IsIPad()); fallbackParam != "" {
+		if target, _ := redirectTarget(queryParams, fallbackParam); target != "" {
+			return target, http.StatusFound, fallbackParam
+		}
 	}
 
 	appStoreID := queryParams.Get("isi")
@@ -238,62 +445,84 @@ func handleiOSDynamicLink(w http.ResponseWriter, r *http.Request, queryParams ur
 		if len(params) > 0 {
 			redirectURL += "?" + strings.Join(params, "&")
 		}
-
-		http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
-		return
+		h.recordClick(r, "ios", dynamicLink, queryParams, device)
+		return redirectURL, http.StatusTemporaryRedirect, model.DecisionAppStore
 	}
+
+	return fallbackTarget(queryParams)
 }
 
-func handleAndroidDynamicLink(w http.ResponseWriter, r *http.Request, queryParams url.Values, dynamicLink *url.URL) {
+func (h *DynamicLinkHandler) handleAndroidDynamicLink(w http.ResponseWriter, r *http.Request, queryParams url.Values, dynamicLink *url.URL, device utils.Device) {
 	log.Debug().Msg("Handling Android dynamic link")
 
-	redirectIfValid := func(paramName string) bool {
-		link := queryParams.Get(paramName)
-		if link == "" {
-			return false
-		}
-		unescapedLink, err := url.QueryUnescape(link)
-		if err != nil {
-			log.Error().Str(paramName, link).Msgf("Failed to unescape '%s' parameter", paramName)
-			http.Error(w, "Invalid '"+paramName+"' link format", http.StatusInternalServerError)
-			return true
+	target, status, decision := h.androidTarget(r, queryParams, dynamicLink, device)
+	if h.escapesInAppBrowser(device) {
+		if appURL := androidAppURL(queryParams, target); appURL != "" {
+			recordDecision(r, model.DecisionEscapePage, appURL)
+			span := traceStage(r, "render escape.html")
+			defer span.End()
+			h.handleEscapePage(w, r, device, appURL, target)
+			return
 		}
-
-		parsedURL, err := url.Parse(unescapedLink)
-		if err != nil || !parsedURL.IsAbs() {
-			log.Error().Str(paramName, unescapedLink).Msgf("Invalid '%s' link", paramName)
-			http.Error(w, "Invalid '"+paramName+"' link format", http.StatusInternalServerError)
-			return true
+	}
+	if intentRedirect(queryParams, h.config.AndroidIntentRedirect == "true") && supportsIntentURLs(device) {
+		if appURL := androidAppURL(queryParams, target); appURL != "" {
+			log.Debug().Str("redirectURL", appURL).Msg("Redirecting to Android intent")
+			recordDecision(r, model.DecisionIntent, appURL)
+			http.Redirect(w, r, appURL, http.StatusFound)
+			return
 		}
+	}
+	h.redirectOrDefault(w, r, target, status, decision, "android")
+}
 
-		http.Redirect(w, r, unescapedLink, http.StatusFound)
+// supportsIntentURLs reports whether the browser opens intent:// URLs and honors their
+// S.browser_fallback_url. Chromium-based browsers do; embedded webviews do not.
+func supportsIntentURLs(device utils.Device) bool {
+	if device.InAppBrowser != "" {
+		return false
+	}
+	switch device.Browser {
+	case "chrome", "samsung", "edge", "opera":
 		return true
 	}
+	return false
+}
 
-	if redirectIfValid("afl") {
-		return
+// androidTarget returns where an Android device is sent when the app does not open the link, with
+// the redirect status and the decision taken, or "" when none of the link's parameters applies.
+// Play Store redirects are recorded for install attribution and carry the click token in the
+// referrer.
+func (h *DynamicLinkHandler) androidTarget(r *http.Request, queryParams url.Values, dynamicLink *url.URL, device utils.Device) (string, int, string) {
+	if target, _ := redirectTarget(queryParams, "afl"); target != "" {
+		return target, http.StatusFound, "afl"
 	}
 
 	appPackageName := queryParams.Get("apn")
 	if appPackageName != "" {
-		dynamicLink.RawQuery = ""
-		redirectURL := fmt.Sprintf("https://play.google.com/store/apps/details?id=%s&referrer=tracking_id%%3D%s", appPackageName, dynamicLink)
+		storeLink := *dynamicLink
+		storeLink.RawQuery = ""
+		clickID := h.recordClick(r, "android", dynamicLink, queryParams, device)
+		redirectURL := fmt.Sprintf("https://play.google.com/store/apps/details?id=%s&referrer=%s", appPackageName, playStoreReferrer(&storeLink, queryParams, clickID))
 		log.Debug().Str("redirectURL", redirectURL).Msg("Redirecting to Play Store")
-		http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
-		return
+		return redirectURL, http.StatusTemporaryRedirect, model.DecisionPlayStore
 	}
-}
 
-func (h *DynamicLinkHandler) AppleAppSiteAssociation(w http.ResponseWriter, r *http.Request) {
-	log.Info().Msg("Processing Apple App Site Association request")
-	http.ServeFile(w, r, "static/apple-app-site-association.json")
+	return fallbackTarget(queryParams)
 }
 
-func domainFallbackRedirect(cfg *config.Config) http.HandlerFunc {
-	fallbackHost := strings.TrimSpace(cfg.FallbackHost)
+// domainFallbackRedirect sends requests no route matched to the fallback host of the tenant
+// serving the requested host. Tenants without one answer 404.
+func domainFallbackRedirect(linkService *service.DynamicLinkService) http.HandlerFunc {
 	defaultScheme := "https"
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		fallbackHost := linkService.Tenant(r.Host).FallbackHost
+		if fallbackHost == "" {
+			http.NotFound(w, r)
+			return
+		}
+
  		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
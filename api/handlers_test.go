@@ -0,0 +1,1203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+)
+
+const (
+	testiPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	testiPadUA    = "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	testAndroidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	testDesktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+)
+
+// TestMain runs the tests from the repository root, where the server expects templates/.
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func newTestRouterWithResolver(cfg *config.Config, resolver service.LinkResolver, store service.LinkStore) http.Handler {
+	return newTestRouterWithEvents(cfg, resolver, store, nil, nil)
+}
+
+func newTestRouterWithEvents(cfg *config.Config, resolver service.LinkResolver, store service.LinkStore, clickEvents *service.ClickEventPipeline, linkStats *service.LinkStats) http.Handler {
+	linkService := service.NewDynamicLinkService(cfg, nil, resolver)
+	shortLinkService := service.NewShortLinkService(cfg, store, linkService)
+	var attributionService *service.InstallAttributionService
+	if cfg.InstallAttribution == "true" {
+		var err error
+		if attributionService, err = service.NewInstallAttributionService(cfg, linkService); err != nil {
+			panic(err)
+		}
+	}
+	return NewRouter(cfg, linkService, shortLinkService, RouterOptions{Attribution: attributionService, ClickEvents: clickEvents, LinkStats: linkStats})
+}
+
+func newTestRouter(links map[string]string) http.Handler {
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated"}
+	return newTestRouterWithResolver(cfg, service.NewMemoryLinkResolver(links), service.NewMemoryLinkStore())
+}
+
+func TestHandleRedirect(t *testing.T) {
+	router := newTestRouter(map[string]string{
+		"example.page.link/web":     "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&ofl=https%3A%2F%2Fexample.com%2Fdesktop",
+		"example.page.link/android": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example",
+	})
+
+	tests := []struct {
+		name             string
+		target           string
+		userAgent        string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "desktop redirects to ofl",
+			target:           "https://example.page.link/web",
+			userAgent:        testDesktopUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+		{
+			name:             "android redirects to preview",
+			target:           "https://example.page.link/android",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://preview-example.page.link/android",
+		},
+		{
+			name:             "android from preview redirects to play store",
+			target:           "https://example.page.link/android?from-preview=true",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://play.google.com/store/apps/details?id=com.example&referrer=tracking_id%3Dhttps://example.page.link/android",
+		},
+		{
+			name:           "unknown link",
+			target:         "https://example.page.link/missing",
+			userAgent:      testDesktopUA,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+		})
+	}
+}
+
+type errorResolver struct {
+	err error
+}
+
+func (r errorResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	return nil, r.err
+}
+
+func TestHandleRedirectBackendErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "circuit open", err: service.ErrExchangeUnavailable, expectedStatus: http.StatusServiceUnavailable},
+		{name: "backend failure", err: &service.ExchangeError{StatusCode: http.StatusInternalServerError}, expectedStatus: http.StatusBadGateway},
+		{name: "other error", err: errors.New("boom"), expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{PreviewUrlStyle: "hyphenated"}
+			router := newTestRouterWithResolver(cfg, errorResolver{err: tt.err}, service.NewMemoryLinkStore())
+
+			req := httptest.NewRequest(http.MethodGet, "https://example.page.link/abc", nil)
+			req.Header.Set("User-Agent", testDesktopUA)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestHandleRedirectLinkWindow(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	longLink := "https://example.page.link/?link=https%3A%2F%2Fexample.com&ofl=https%3A%2F%2Fexample.com%2Fdesktop"
+
+	tests := []struct {
+		name             string
+		behavior         string
+		longLink         string
+		notBefore        *time.Time
+		expiresAt        *time.Time
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "active",
+			notBefore:        &past,
+			expiresAt:        &future,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+		{
+			name:           "not yet active",
+			notBefore:      &future,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "expired serves gone page",
+			behavior:       "gone",
+			expiresAt:      &past,
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:             "expired redirects to fallback",
+			behavior:         "fallback",
+			expiresAt:        &past,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/expired",
+		},
+		{
+			name:             "expired redirects to ofl",
+			behavior:         "ofl",
+			expiresAt:        &past,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+		{
+			name:             "expired redirects to escaped ofl",
+			behavior:         "ofl",
+			longLink:         "https://example.page.link/?link=https%3A%2F%2Fexample.com&ofl=https%253A%252F%252Fexample.com%252Fdesktop",
+			expiresAt:        &past,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.longLink == "" {
+				tt.longLink = longLink
+			}
+			cfg := &config.Config{
+				PreviewUrlStyle:        "hyphenated",
+				ExpiredLinkBehavior:    tt.behavior,
+				ExpiredLinkFallbackURL: "https://example.com/expired",
+			}
+			store := service.NewMemoryLinkStore()
+			store.Create(context.Background(), &model.DynamicLink{
+				Domain:    "example.page.link",
+				ShortCode: "promo",
+				LongLink:  tt.longLink,
+				NotBefore: tt.notBefore,
+				ExpiresAt: tt.expiresAt,
+			})
+			router := newTestRouterWithResolver(cfg, service.NewStoreLinkResolver(store), store)
+
+			req := httptest.NewRequest(http.MethodGet, "https://example.page.link/promo", nil)
+			req.Header.Set("User-Agent", testDesktopUA)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+		})
+	}
+}
+
+func TestHandleRedirectFirebaseParameters(t *testing.T) {
+	router := newTestRouter(map[string]string{
+		"example.page.link/efr":      "https://example.page.link/?link=https%3A%2F%2Fexample.com&apn=com.example&isi=123&efr=1",
+		"example.page.link/ifl":      "https://example.page.link/?link=https%3A%2F%2Fexample.com&isi=123&ifl=https%3A%2F%2Fexample.com%2Fios",
+		"example.page.link/ipbi":     "https://example.page.link/?link=https%3A%2F%2Fexample.com&isi=123&ifl=https%3A%2F%2Fexample.com%2Fios&ipbi=com.example.ipad",
+		"example.page.link/ipfl":     "https://example.page.link/?link=https%3A%2F%2Fexample.com&isi=123&ifl=https%3A%2F%2Fexample.com%2Fios&ipfl=https%3A%2F%2Fexample.com%2Fipad",
+		"example.page.link/campaign": "https://example.page.link/?link=https%3A%2F%2Fexample.com&apn=com.example&utm_source=news&utm_campaign=spring+sale",
+	})
+
+	tests := []struct {
+		name             string
+		target           string
+		userAgent        string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "efr skips preview on android",
+			target:           "https://example.page.link/efr",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://play.google.com/store/apps/details?id=com.example&referrer=tracking_id%3Dhttps://example.page.link/efr",
+		},
+		{
+			name:             "efr skips preview on iOS",
+			target:           "https://example.page.link/efr",
+			userAgent:        testiPhoneUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://apps.apple.com/app/id123",
+		},
+		{
+			name:             "iPad without ipbi uses ifl",
+			target:           "https://example.page.link/ifl",
+			userAgent:        testiPadUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/ios",
+		},
+		{
+			name:             "iPad with ipbi ignores ifl",
+			target:           "https://example.page.link/ipbi?from-preview=true",
+			userAgent:        testiPadUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://apps.apple.com/app/id123",
+		},
+		{
+			name:             "iPad prefers ipfl",
+			target:           "https://example.page.link/ipfl",
+			userAgent:        testiPadUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/ipad",
+		},
+		{
+			name:             "iPhone ignores ipfl",
+			target:           "https://example.page.link/ipfl",
+			userAgent:        testiPhoneUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/ios",
+		},
+		{
+			name:             "campaign parameters forwarded to play store referrer",
+			target:           "https://example.page.link/campaign?from-preview=true",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://play.google.com/store/apps/details?id=com.example&referrer=tracking_id%3Dhttps://example.page.link/campaign%26utm_campaign%3Dspring%2Bsale%26utm_source%3Dnews",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+		})
+	}
+}
+
+func TestHandleRedirectLongLink(t *testing.T) {
+	tests := []struct {
+		name             string
+		allowedHosts     string
+		target           string
+		userAgent        string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "desktop redirects to ofl",
+			allowedHosts:     "example.com",
+			target:           "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&ofl=https%3A%2F%2Fexample.com%2Fdesktop",
+			userAgent:        testDesktopUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+		{
+			name:             "android redirects to preview",
+			allowedHosts:     "example.com",
+			target:           "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://preview-example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example",
+		},
+		{
+			name:             "android from preview redirects to play store",
+			allowedHosts:     "example.com",
+			target:           "https://example.page.link/?apn=com.example&from-preview=true&link=https%3A%2F%2Fexample.com%2Fpost",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://play.google.com/store/apps/details?id=com.example&referrer=tracking_id%3Dhttps://example.page.link/",
+		},
+		{
+			name:             "allowed host",
+			allowedHosts:     "*.example.com, example.com",
+			target:           "https://example.page.link/?link=https%3A%2F%2Fwww.example.com%2Fpost",
+			userAgent:        testDesktopUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://www.example.com/post",
+		},
+		{
+			name:           "disallowed host",
+			allowedHosts:   "example.com",
+			target:         "https://example.page.link/?link=https%3A%2F%2Fexample.com&ofl=https%3A%2F%2Fevil.example.org",
+			userAgent:      testDesktopUA,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "disallowed social image",
+			allowedHosts:   "example.com",
+			target:         "https://example.page.link/?link=https%3A%2F%2Fexample.com&si=https%3A%2F%2Fevil.example.org%2Fimage.png",
+			userAgent:      testDesktopUA,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "disabled without allowed hosts",
+			target:         "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost",
+			userAgent:      testDesktopUA,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "root without link",
+			target:         "https://example.page.link/?apn=com.example",
+			userAgent:      testDesktopUA,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{PreviewUrlStyle: "hyphenated", LongLinkAllowedHosts: tt.allowedHosts}
+			router := newTestRouterWithResolver(cfg, errorResolver{err: errors.New("exchange must not be called")}, service.NewMemoryLinkStore())
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+		})
+	}
+}
+
+func TestHandleRedirectFallbackChain(t *testing.T) {
+	links := map[string]string{
+		"example.page.link/ofl":     "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Flink&ofl=https%3A%2F%2Fexample.com%2Fofl",
+		"example.page.link/link":    "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Flink",
+		"example.page.link/invalid": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Flink&afl=not-a-url&ifl=not-a-url",
+		"example.page.link/empty":   "https://example.page.link/?link=not-a-url",
+	}
+
+	tests := []struct {
+		name             string
+		target           string
+		userAgent        string
+		expectedLocation string
+	}{
+		{name: "android without apn uses ofl", target: "https://example.page.link/ofl?from-preview=true", userAgent: testAndroidUA, expectedLocation: "https://example.com/ofl"},
+		{name: "iOS without isi uses ofl", target: "https://example.page.link/ofl?from-preview=true", userAgent: testiPhoneUA, expectedLocation: "https://example.com/ofl"},
+		{name: "android without ofl uses link", target: "https://example.page.link/link?from-preview=true", userAgent: testAndroidUA, expectedLocation: "https://example.com/link"},
+		{name: "iOS without ofl uses link", target: "https://example.page.link/link?from-preview=true", userAgent: testiPhoneUA, expectedLocation: "https://example.com/link"},
+		{name: "invalid afl falls through", target: "https://example.page.link/invalid", userAgent: testAndroidUA, expectedLocation: "https://example.com/link"},
+		{name: "invalid ifl falls through", target: "https://example.page.link/invalid", userAgent: testiPhoneUA, expectedLocation: "https://example.com/link"},
+		{name: "android default", target: "https://example.page.link/empty?from-preview=true", userAgent: testAndroidUA, expectedLocation: "https://example.com/default"},
+		{name: "iOS default", target: "https://example.page.link/empty?from-preview=true", userAgent: testiPhoneUA, expectedLocation: "https://example.com/default"},
+		{name: "desktop default", target: "https://example.page.link/empty", userAgent: testDesktopUA, expectedLocation: "https://example.com/default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{PreviewUrlStyle: "hyphenated", DefaultRedirectURL: "https://example.com/default"}
+			router := newTestRouterWithResolver(cfg, service.NewMemoryLinkResolver(links), service.NewMemoryLinkStore())
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusFound {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusFound)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+		})
+	}
+}
+
+func TestHandleRedirectDefaultPageCounted(t *testing.T) {
+	router := newTestRouter(map[string]string{
+		"example.page.link/empty": "https://example.page.link/?apn=",
+	})
+
+	before := expvarInt(defaultFallbacks.Get("web"))
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.page.link/empty", nil)
+	req.Header.Set("User-Agent", testDesktopUA)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Body.Len() == 0 {
+		t.Error("default fallback wrote an empty body")
+	}
+	if after := expvarInt(defaultFallbacks.Get("web")); after != before+1 {
+		t.Errorf("redirectDefaultFallbacks[web] = %d, want %d", after, before+1)
+	}
+}
+
+func expvarInt(v expvar.Var) int64 {
+	if n, ok := v.(*expvar.Int); ok {
+		return n.Value()
+	}
+	return 0
+}
+
+func TestHandleRedirectDeviceClassification(t *testing.T) {
+	const (
+		macSafariUA     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+		androidTabletUA = "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+		chromebookUA    = "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	)
+
+	router := newTestRouter(map[string]string{
+		"example.page.link/ios": "https://example.page.link/?link=https%3A%2F%2Fexample.com&apn=com.example&ifl=https%3A%2F%2Fexample.com%2Fios&afl=https%3A%2F%2Fexample.com%2Fandroid&ofl=https%3A%2F%2Fexample.com%2Fdesktop",
+		"example.page.link/web": "https://example.page.link/?link=https%3A%2F%2Fexample.com&ofl=https%3A%2F%2Fexample.com%2Fdesktop",
+	})
+
+	tests := []struct {
+		name             string
+		target           string
+		userAgent        string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			name:           "mac safari gets detection page",
+			target:         "https://example.page.link/ios",
+			userAgent:      macSafariUA,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "platform-hint=ipados",
+		},
+		{
+			name:             "mac safari without iOS parameters skips detection",
+			target:           "https://example.page.link/web",
+			userAgent:        macSafariUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+		{
+			name:             "iPad in desktop mode routed as iPad",
+			target:           "https://example.page.link/ios?platform-hint=ipados",
+			userAgent:        macSafariUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/ios",
+		},
+		{
+			name:             "mac confirmed by hint",
+			target:           "https://example.page.link/ios?platform-hint=macos",
+			userAgent:        macSafariUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+		{
+			name:             "android tablet routed as android",
+			target:           "https://example.page.link/ios",
+			userAgent:        androidTabletUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/android",
+		},
+		{
+			name:             "chromebook routed as desktop",
+			target:           "https://example.page.link/ios",
+			userAgent:        chromebookUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/desktop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+			if !strings.Contains(rec.Body.String(), tt.expectedBody) {
+				t.Errorf("body does not contain %q", tt.expectedBody)
+			}
+		})
+	}
+}
+
+func TestHandleRedirectSocialCrawler(t *testing.T) {
+	router := newTestRouter(map[string]string{
+		"example.page.link/promo": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&ofl=https%3A%2F%2Fexample.com%2Fdesktop&st=Spring+Sale&sd=Half+off&si=https%3A%2F%2Fexample.com%2Fsale.png",
+	})
+
+	crawlers := map[string]string{
+		"slack":    "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+		"imessage": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_1) AppleWebKit/601.2.4 (KHTML, like Gecko) Version/9.0.1 Safari/601.2.4 facebookexternalhit/1.1 Facebot Twitterbot/1.0",
+		"facebook": "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+		"twitter":  "Twitterbot/1.0",
+		"whatsapp": "WhatsApp/2.23.20.0 A",
+		"discord":  "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)",
+	}
+
+	for name, userAgent := range crawlers {
+		for _, target := range []string{"https://example.page.link/promo", "https://preview-example.page.link/promo"} {
+			t.Run(name+" "+target, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, target, nil)
+				req.Header.Set("User-Agent", userAgent)
+				rec := httptest.NewRecorder()
+
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+				}
+				body := rec.Body.String()
+				for _, tag := range []string{
+					`<meta property="og:title" content="Spring Sale" />`,
+					`<meta property="og:description" content="Half off" />`,
+					`<meta property="og:image" content="https://example.com/sale.png" />`,
+					`<meta name="twitter:card" content="summary_large_image" />`,
+					`<meta property="og:url" content="https://example.page.link/promo" />`,
+				} {
+					if !strings.Contains(body, tag) {
+						t.Errorf("body does not contain %s", tag)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestHandleRedirectInAppBrowserEscape(t *testing.T) {
+	const (
+		instagramUA       = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_3_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 321.0.2.24.105 (iPhone15,2; iOS 17_3_1; en_US; en; scale=3.00; 1179x2556; 566948718)"
+		facebookAndroidUA = "Mozilla/5.0 (Linux; Android 13; SM-S908B Build/TP1A.220624.014; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.43 Mobile Safari/537.36 [FB_IAB/FB4A;FBAV/445.0.0.34.118;]"
+	)
+
+	links := map[string]string{
+		"example.page.link/app":       "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost%3Fid%3D7&apn=com.example&ius=exampleapp&isi=123",
+		"example.page.link/noscheme":  "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&isi=123",
+		"example.page.link/bundle":    "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&ibi=com.example.ios&imv=2.1&isi=123",
+		"example.page.link/script":    "https://example.page.link/?link=%0Aalert(document.domain)&ius=JavaScript&isi=123",
+		"example.page.link/scriptafl": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example&afl=javascript%3Aalert(document.domain)",
+		"example.page.link/fallbacks": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example&afl=https%3A%2F%2Fexample.com%2Fandroid",
+	}
+
+	tests := []struct {
+		name             string
+		families         string
+		target           string
+		userAgent        string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     []string
+	}{
+		{
+			name:           "instagram on iOS tries custom scheme",
+			families:       "instagram",
+			target:         "https://example.page.link/app",
+			userAgent:      instagramUA,
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`href="exampleapp://google/link/?deep_link_id=https%3A%2F%2Fexample.com%2Fpost%3Fid%3D7"`,
+				`href="https://apps.apple.com/app/id123"`,
+				"1500",
+			},
+		},
+		{
+			name:           "facebook on android tries intent",
+			families:       "*",
+			target:         "https://example.page.link/fallbacks",
+			userAgent:      facebookAndroidUA,
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`href="intent://example.com/post#Intent;scheme=https;package=com.example;S.browser_fallback_url=https%3A%2F%2Fexample.com%2Fandroid;end"`,
+				`href="https://example.com/android"`,
+				"3000",
+			},
+		},
+		{
+			name:           "bundle ID is the default custom scheme",
+			families:       "instagram",
+			target:         "https://example.page.link/bundle",
+			userAgent:      instagramUA,
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`href="com.example.ios://google/link/?deep_link_id=https%3A%2F%2Fexample.com%2Fpost&amp;imv=2.1"`,
+			},
+		},
+		{
+			name:             "script scheme is not an app",
+			families:         "instagram",
+			target:           "https://example.page.link/script?from-preview=true",
+			userAgent:        instagramUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://apps.apple.com/app/id123",
+		},
+		{
+			name:           "script fallback is not a target",
+			families:       "*",
+			target:         "https://example.page.link/scriptafl",
+			userAgent:      facebookAndroidUA,
+			expectedStatus: http.StatusOK,
+			expectedBody: []string{
+				`href="https://play.google.com/store/apps/details?id=com.example`,
+			},
+		},
+		{
+			name:             "family not configured",
+			families:         "facebook",
+			target:           "https://example.page.link/app?from-preview=true",
+			userAgent:        instagramUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://apps.apple.com/app/id123",
+		},
+		{
+			name:             "no custom scheme",
+			families:         "instagram",
+			target:           "https://example.page.link/noscheme?from-preview=true",
+			userAgent:        instagramUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://apps.apple.com/app/id123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{PreviewUrlStyle: "hyphenated", InAppBrowserEscape: tt.families, InAppBrowserEscapeTimeout: "1500ms,facebook:3s"}
+			router := newTestRouterWithResolver(cfg, service.NewMemoryLinkResolver(links), service.NewMemoryLinkStore())
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+			for _, expected := range tt.expectedBody {
+				if !strings.Contains(rec.Body.String(), expected) {
+					t.Errorf("body does not contain %s", expected)
+				}
+			}
+		})
+	}
+}
+
+func TestParseEscapeTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]time.Duration
+	}{
+		{
+			name:     "empty uses default",
+			value:    "",
+			expected: map[string]time.Duration{"instagram": 2 * time.Second, "tiktok": 2 * time.Second},
+		},
+		{
+			name:     "default only",
+			value:    "3s",
+			expected: map[string]time.Duration{"instagram": 3 * time.Second, "tiktok": 3 * time.Second},
+		},
+		{
+			name:     "family overrides",
+			value:    "1s, Instagram:3s ,tiktok:1500ms",
+			expected: map[string]time.Duration{"instagram": 3 * time.Second, "tiktok": 1500 * time.Millisecond, "facebook": time.Second},
+		},
+		{
+			name:     "invalid entries are skipped",
+			value:    "soon,instagram:0s,tiktok:x,:3s,facebook:4s",
+			expected: map[string]time.Duration{"instagram": 2 * time.Second, "tiktok": 2 * time.Second, "facebook": 4 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeouts := parseEscapeTimeouts(tt.value)
+			for family, expected := range tt.expected {
+				if timeout := timeouts.forFamily(family); timeout != expected {
+					t.Errorf("forFamily(%q) = %v, want %v", family, timeout, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRedirectAndroidIntent(t *testing.T) {
+	const firefoxAndroidUA = "Mozilla/5.0 (Android 14; Mobile; rv:125.0) Gecko/125.0 Firefox/125.0"
+
+	links := map[string]string{
+		"example.page.link/default": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example&amv=42",
+		"example.page.link/on":      "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example&intent=1",
+		"example.page.link/off":     "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example&intent=0",
+	}
+	const playStore = "https%3A%2F%2Fplay.google.com%2Fstore%2Fapps%2Fdetails%3Fid%3Dcom.example%26referrer%3Dtracking_id%253Dhttps%3A%2F%2Fexample.page.link%2F"
+
+	tests := []struct {
+		name             string
+		global           string
+		target           string
+		userAgent        string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "enabled globally",
+			global:           "true",
+			target:           "https://example.page.link/default?from-preview=true",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "intent://example.com/post#Intent;scheme=https;package=com.example;S.amv=42;S.browser_fallback_url=" + playStore + "default;end",
+		},
+		{
+			name:             "enabled for link",
+			global:           "false",
+			target:           "https://example.page.link/on?from-preview=true",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "intent://example.com/post#Intent;scheme=https;package=com.example;S.browser_fallback_url=" + playStore + "on;end",
+		},
+		{
+			name:             "disabled for link",
+			global:           "true",
+			target:           "https://example.page.link/off?from-preview=true",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://play.google.com/store/apps/details?id=com.example&referrer=tracking_id%3Dhttps://example.page.link/off",
+		},
+		{
+			name:             "disabled globally",
+			global:           "false",
+			target:           "https://example.page.link/default?from-preview=true",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://play.google.com/store/apps/details?id=com.example&referrer=tracking_id%3Dhttps://example.page.link/default",
+		},
+		{
+			name:             "browser without intent support",
+			global:           "true",
+			target:           "https://example.page.link/default?from-preview=true",
+			userAgent:        firefoxAndroidUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://play.google.com/store/apps/details?id=com.example&referrer=tracking_id%3Dhttps://example.page.link/default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{PreviewUrlStyle: "hyphenated", AndroidIntentRedirect: tt.global}
+			router := newTestRouterWithResolver(cfg, service.NewMemoryLinkResolver(links), service.NewMemoryLinkStore())
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+		})
+	}
+}
+
+func TestInstallAttributionFlow(t *testing.T) {
+	cfg := &config.Config{
+		PreviewUrlStyle:          "hyphenated",
+		InstallAttribution:       "true",
+		AttributionWindow:        "1h",
+		AttributionMinConfidence: "0.6",
+		AttributionMaxClicks:     "100",
+		ShortLinksAPIKey:         "secret",
+	}
+	router := newTestRouterWithResolver(cfg, service.NewMemoryLinkResolver(map[string]string{
+		"example.page.link/promo": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpromo&apn=com.example&isi=123&utm_source=news",
+	}), service.NewMemoryLinkStore())
+
+	attribute := func(t *testing.T, body string) model.InstallAttributionResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/installAttribution?key=secret", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var response model.InstallAttributionResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	t.Run("android play install referrer", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "https://example.page.link/promo?from-preview=true", nil)
+		req.Header.Set("User-Agent", testAndroidUA)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		location, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil || location.Host != "play.google.com" {
+			t.Fatalf("Location = %q, want Play Store", rec.Header().Get("Location"))
+		}
+		referrer := location.Query().Get("referrer")
+		if !strings.Contains(referrer, "click_id=") {
+			t.Fatalf("referrer %q has no click_id", referrer)
+		}
+
+		body, _ := json.Marshal(model.InstallAttributionRequest{Platform: "android", InstallReferrer: referrer})
+		response := attribute(t, string(body))
+		if response.MatchType != model.MatchUnique || response.DeepLink != "https://example.com/promo" || response.UTMSource != "news" {
+			t.Errorf("response = %+v, want unique match for the promo link", response)
+		}
+	})
+
+	t.Run("ios probabilistic match", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "https://example.page.link/promo?from-preview=true&screen=390x844%403", nil)
+		req.Header.Set("User-Agent", testiPhoneUA)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if location := rec.Header().Get("Location"); location != "https://apps.apple.com/app/id123" {
+			t.Fatalf("Location = %q, want App Store", location)
+		}
+
+		response := attribute(t, `{"platform":"ios","osVersion":"17.4","screen":"390x844@3"}`)
+		if response.MatchType != model.MatchDefault || response.DeepLink != "https://example.com/promo" {
+			t.Errorf("response = %+v, want default match for the promo link", response)
+		}
+
+		response = attribute(t, `{"platform":"ios","osVersion":"17.4","screen":"390x844@3"}`)
+		if response.MatchType != model.MatchNone {
+			t.Errorf("response = %+v, want the click to attribute only one install", response)
+		}
+	})
+
+	t.Run("missing API key", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/installAttribution", strings.NewReader(`{"platform":"ios"}`))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("invalid platform", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/installAttribution?key=secret", strings.NewReader(`{"platform":"web"}`))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestClipboardHandoff(t *testing.T) {
+	cfg := &config.Config{
+		PreviewUrlStyle:          "hyphenated",
+		InstallAttribution:       "true",
+		AttributionWindow:        "1h",
+		AttributionMinConfidence: "0.6",
+		AttributionMaxClicks:     "100",
+		ClipboardHandoff:         "true",
+		ClipboardTokenSecret:     "0123456789abcdef",
+		ClipboardTokenTTL:        "10m",
+		ShortLinksAPIKey:         "secret",
+	}
+	router := newTestRouterWithResolver(cfg, service.NewMemoryLinkResolver(map[string]string{
+		"example.page.link/promo": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpromo&isi=123",
+	}), service.NewMemoryLinkStore())
+
+	req := httptest.NewRequest(http.MethodGet, "https://preview-example.page.link/promo", nil)
+	req.Header.Set("User-Agent", testiPhoneUA)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want preview page", rec.Code)
+	}
+	start := strings.Index(rec.Body.String(), `const clipboardText = "`)
+	if start < 0 {
+		t.Fatal("preview page has no clipboard token")
+	}
+	token := rec.Body.String()[start+len(`const clipboardText = "`):]
+	token = token[:strings.IndexByte(token, '"')]
+
+	body, _ := json.Marshal(model.ExchangeClipboardTokenRequest{Token: token})
+	for _, target := range []string{"/v1/exchangeClipboardToken", "/v1/exchangeClipboardToken?key=wrong"} {
+		req = httptest.NewRequest(http.MethodPost, target, strings.NewReader(string(body)))
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, http.StatusForbidden)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/exchangeClipboardToken?key=secret", strings.NewReader(string(body)))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var response model.InstallAttributionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.DeepLink != "https://example.com/promo" || response.RequestedLink != "https://example.page.link/promo" {
+		t.Errorf("response = %+v, want the promo deep link", response)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/exchangeClipboardToken?key=secret", strings.NewReader(`{"token":"dlr1.bogus.token"}`))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d for a forged token", rec.Code, http.StatusBadRequest)
+	}
+}
+
+type recordingClickEventSink struct {
+	events []model.ClickEvent
+}
+
+func (s *recordingClickEventSink) Name() string { return "recording" }
+
+func (s *recordingClickEventSink) Write(ctx context.Context, events []model.ClickEvent) error {
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *recordingClickEventSink) Close() error { return nil }
+
+func TestHandleRedirectClickEvents(t *testing.T) {
+	links := map[string]string{
+		"example.page.link/promo": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpromo&apn=com.example&ofl=https%3A%2F%2Fexample.com%2Fdesktop&utm_source=newsletter&utm_campaign=spring",
+	}
+	sink := &recordingClickEventSink{}
+	pipeline := service.NewClickEventPipeline([]service.ClickEventSink{sink}, service.ClickEventOptions{})
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated", CountryHeader: "CF-IPCountry"}
+	router := newTestRouterWithEvents(cfg, service.NewMemoryLinkResolver(links), service.NewMemoryLinkStore(), pipeline, nil)
+
+	requests := []struct {
+		url       string
+		userAgent string
+	}{
+		{"https://example.page.link/promo", testDesktopUA},
+		{"https://preview-example.page.link/promo", testAndroidUA},
+		{"https://example.page.link/promo?from-preview=true", testAndroidUA},
+		{"https://example.page.link/missing", testDesktopUA},
+	}
+	for _, request := range requests {
+		req := httptest.NewRequest(http.MethodGet, request.url, nil)
+		req.Header.Set("User-Agent", request.userAgent)
+		req.Header.Set("Referer", "https://news.example.org/")
+		req.Header.Set("CF-IPCountry", "de")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if err := pipeline.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.events) != len(requests) {
+		t.Fatalf("got %d events, want %d: %+v", len(sink.events), len(requests), sink.events)
+	}
+
+	tests := []struct {
+		platform     string
+		decision     string
+		targetPrefix string
+		previewShown bool
+	}{
+		{"web", "ofl", "https://example.com/desktop", false},
+		{"android", model.DecisionPreviewPage, "https://example.page.link/promo?from-preview=true", true},
+		{"android", model.DecisionPlayStore, "https://play.google.com/store/apps/details?id=com.example", true},
+		{"", model.DecisionNotFound, "", false},
+	}
+	for i, tt := range tests {
+		event := sink.events[i]
+		if event.Platform != tt.platform || event.Decision != tt.decision || !strings.HasPrefix(event.TargetURL, tt.targetPrefix) || event.PreviewShown != tt.previewShown {
+			t.Errorf("event %d = %+v, want platform %q decision %q target %q previewShown %v", i, event, tt.platform, tt.decision, tt.targetPrefix, tt.previewShown)
+		}
+		if event.Domain != "example.page.link" || event.Referrer != "https://news.example.org/" || event.Country != "DE" {
+			t.Errorf("event %d = %+v, want request details", i, event)
+		}
+	}
+	if event := sink.events[0]; event.ShortCode != "promo" || event.UTMSource != "newsletter" || event.UTMCampaign != "spring" {
+		t.Errorf("event = %+v, want short code and UTM parameters", event)
+	}
+
+	stats := pipeline.Stats()["recording"]
+	if stats.Emitted != int64(len(requests)) || stats.Written != int64(len(requests)) || stats.Dropped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestHandleRedirectTenants(t *testing.T) {
+	dir := t.TempDir()
+	partnerAssetLinks := filepath.Join(dir, "partner-assetlinks.json")
+	if err := os.WriteFile(partnerAssetLinks, []byte(`[{"target": {"package_name": "com.partner"}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		AppName:         "Default App",
+		PreviewUrlStyle: "hyphenated",
+		EnableFallback:  "true",
+		TenantsFile:     filepath.Join(dir, "tenants.json"),
+	}
+	tenantsJSON := `[{"name": "partner", "hosts": ["partner.page.link"], "appName": "Partner App", "previewUrlStyle": "subdomain",
+		"fallbackHost": "www.partner.com", "appStoreId": "555", "assetLinksFile": "` + partnerAssetLinks + `"}]`
+	if err := os.WriteFile(cfg.TenantsFile, []byte(tenantsJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := service.NewMemoryLinkResolver(map[string]string{
+		"example.page.link/abc": "https://example.page.link/?link=https%3A%2F%2Fexample.com&apn=com.example",
+		"partner.page.link/abc": "https://partner.page.link/?link=https%3A%2F%2Fpartner.com&apn=com.partner",
+	})
+	linkService := service.NewDynamicLinkService(cfg, tenants, resolver)
+	shortLinkService := service.NewShortLinkService(cfg, service.NewMemoryLinkStore(), linkService)
+	router := NewRouter(cfg, linkService, shortLinkService, RouterOptions{})
+
+	serve := func(target, userAgent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name             string
+		target           string
+		userAgent        string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			name:             "tenant preview style",
+			target:           "https://partner.page.link/abc",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://preview.partner.page.link/abc",
+		},
+		{
+			name:             "default preview style for other hosts",
+			target:           "https://example.page.link/abc",
+			userAgent:        testAndroidUA,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://preview-example.page.link/abc",
+		},
+		{
+			name:           "tenant app name on preview page",
+			target:         "https://preview.partner.page.link/abc",
+			userAgent:      testAndroidUA,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Partner App",
+		},
+		{
+			name:             "tenant app store ID fills in missing isi",
+			target:           "https://partner.page.link/abc?from-preview=true",
+			userAgent:        testiPhoneUA,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://apps.apple.com/app/id555",
+		},
+		{
+			name:           "tenant asset links",
+			target:         "https://partner.page.link/.well-known/assetlinks.json",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "com.partner",
+		},
+		{
+			name:             "tenant fallback host",
+			target:           "https://partner.page.link/a/b",
+			expectedStatus:   http.StatusPermanentRedirect,
+			expectedLocation: "https://www.partner.com/a/b",
+		},
+		{
+			name:           "no fallback host for the default tenant",
+			target:         "https://example.page.link/a/b",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.target, tt.userAgent)
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.expectedStatus)
+			}
+			if location := rec.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Location = %v, want %v", location, tt.expectedLocation)
+			}
+			if !strings.Contains(rec.Body.String(), tt.expectedBody) {
+				t.Errorf("body does not contain %q", tt.expectedBody)
+			}
+		})
+	}
+}
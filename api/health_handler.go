@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+)
+
+// The health endpoints live under /-/, which has two path segments and so can never be taken
+// for a short code.
+const (
+	healthzPath = "/-/healthz"
+	readyzPath  = "/-/readyz"
+	statusPath  = "/-/status"
+)
+
+type HealthHandler struct {
+	health *service.HealthService
+}
+
+func NewHealthHandler(health *service.HealthService) *HealthHandler {
+	return &HealthHandler{health: health}
+}
+
+// Healthz reports that the process is alive and serving HTTP. It does not look at dependencies,
+// so a failing backend never gets the process restarted.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": model.HealthStatusOK})
+}
+
+// Readyz answers 503 while a check that is not optional fails or the server drains for shutdown,
+// which takes it out of the load balancer.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	status := h.health.Status()
+	code := http.StatusOK
+	if status.Status != model.HealthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]string{"status": status.Status})
+}
+
+// Status serves every check with its error and duration for operators. It answers 200 even when
+// failing so that the body is readable by any client; the status field tells the outcome.
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.health.Status())
+}
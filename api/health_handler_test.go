@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var checkErr error
+	health := service.NewHealthService(
+		service.HealthCheck{Name: "backend", Check: func(ctx context.Context) error { return checkErr }},
+		service.TemplatesHealthCheck("templates"),
+	)
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated", AdminPort: "9090"}
+	resolver := service.NewMemoryLinkResolver(map[string]string{
+		"example.page.link/healthz": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost",
+	})
+	linkService := service.NewDynamicLinkService(cfg, nil, resolver)
+	shortLinkService := service.NewShortLinkService(cfg, service.NewMemoryLinkStore(), linkService)
+	router := NewRouter(cfg, linkService, shortLinkService, RouterOptions{Health: health})
+	adminRouter := NewAdminRouter(health)
+
+	serve := func(handler http.Handler, target string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var body struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Status
+	}
+
+	if code, status := serve(router, "https://example.page.link/-/healthz"); code != http.StatusOK || status != model.HealthStatusOK {
+		t.Errorf("healthz = %d %q, want %d %q", code, status, http.StatusOK, model.HealthStatusOK)
+	}
+	if code, status := serve(router, "https://example.page.link/-/readyz"); code != http.StatusOK || status != model.HealthStatusOK {
+		t.Errorf("readyz = %d %q, want %d %q", code, status, http.StatusOK, model.HealthStatusOK)
+	}
+	// A short code that happens to be named like a probe is still a link.
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://example.page.link/healthz", nil))
+	if rec.Code != http.StatusFound {
+		t.Errorf("/healthz status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	// With an admin port the detailed status is only served there.
+	if code, _ := serve(router, "https://example.page.link/-/status"); code != http.StatusNotFound {
+		t.Errorf("public status = %d, want %d", code, http.StatusNotFound)
+	}
+	rec = httptest.NewRecorder()
+	adminRouter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/status", nil))
+	var status model.HealthStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || status.Status != model.HealthStatusOK || len(status.Checks) != 2 {
+		t.Errorf("status = %d %+v", rec.Code, status)
+	}
+
+	// The failure shows once the cached result expires; draining shows at once.
+	checkErr = errors.New("connection refused")
+	health.Drain()
+	if code, status := serve(router, "https://example.page.link/-/readyz"); code != http.StatusServiceUnavailable || status != model.HealthStatusDraining {
+		t.Errorf("draining readyz = %d %q, want %d %q", code, status, http.StatusServiceUnavailable, model.HealthStatusDraining)
+	}
+	if code, _ := serve(router, "https://example.page.link/-/healthz"); code != http.StatusOK {
+		t.Errorf("draining healthz = %d, want %d", code, http.StatusOK)
+	}
+}
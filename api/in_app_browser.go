@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"dynamic-link-redirect/metrics"
+	"dynamic-link-redirect/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultEscapeTimeout = 2 * time.Second
+
+var customSchemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*$`)
+
+// blockedCustomSchemes run code or load content in the page instead of opening an app. The app
+// URL bypasses html/template's URL sanitizing, so a link must never be able to pick one of them.
+var blockedCustomSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+	"blob":       true,
+	"file":       true,
+	"about":      true,
+	"http":       true,
+	"https":      true,
+	"intent":     true,
+}
+
+// isCustomScheme reports whether scheme is safe to use as an app's custom URL scheme.
+func isCustomScheme(scheme string) bool {
+	return customSchemePattern.MatchString(scheme) && !blockedCustomSchemes[strings.ToLower(scheme)]
+}
+
+// parseEscapeFamilies reads IN_APP_BROWSER_ESCAPE: a comma separated list of in-app browser
+// families, or "*" for every detected family.
+func parseEscapeFamilies(value string) map[string]bool {
+	families := map[string]bool{}
+	for _, family := range strings.Split(value, ",") {
+		if family = strings.ToLower(strings.TrimSpace(family)); family != "" {
+			families[family] = true
+		}
+	}
+	return families
+}
+
+// escapeTimeouts holds how long the escape page waits for the app before it continues to the
+// fallback, per in-app browser family.
+type escapeTimeouts struct {
+	fallback time.Duration
+	families map[string]time.Duration
+}
+
+// parseEscapeTimeouts reads IN_APP_BROWSER_ESCAPE_TIMEOUT: a comma separated list of a default
+// duration and family:duration overrides, such as "2s,instagram:3s,tiktok:1500ms". Invalid
+// entries are logged and skipped.
+func parseEscapeTimeouts(value string) escapeTimeouts {
+	timeouts := escapeTimeouts{fallback: defaultEscapeTimeout, families: map[string]time.Duration{}}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		family, duration, hasFamily := strings.Cut(entry, ":")
+		if !hasFamily {
+			family, duration = "", entry
+		}
+		family = strings.ToLower(strings.TrimSpace(family))
+		timeout, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || timeout <= 0 || (hasFamily && family == "") {
+			log.Error().Str("value", entry).Msg("Invalid IN_APP_BROWSER_ESCAPE_TIMEOUT entry, skipping it")
+			continue
+		}
+		if hasFamily {
+			timeouts.families[family] = timeout
+		} else {
+			timeouts.fallback = timeout
+		}
+	}
+	return timeouts
+}
+
+// forFamily returns the timeout of an in-app browser family, or the default.
+func (t escapeTimeouts) forFamily(family string) time.Duration {
+	if timeout, ok := t.families[family]; ok {
+		return timeout
+	}
+	return t.fallback
+}
+
+// escapesInAppBrowser reports whether the device is an in-app browser whose family is configured
+// to get the escape interstitial.
+func (h *DynamicLinkHandler) escapesInAppBrowser(device utils.Device) bool {
+	if device.InAppBrowser == "" {
+		return false
+	}
+	return h.escapeFamilies["*"] || h.escapeFamilies[device.InAppBrowser]
+}
+
+// iosAppURL builds the custom scheme URL the Firebase SDK understands, or "" if the link has no
+// usable custom scheme. As in Firebase the scheme is ius, else the bundle ID of the app that
+// opens the link on the device: ipbi on iPads that have one, else ibi. imv is passed on for the
+// SDK to compare with the installed version, which the server cannot see.
+func iosAppURL(queryParams url.Values, isiPad bool) string {
+	scheme := queryParams.Get("ius")
+	if scheme == "" && isiPad {
+		scheme = queryParams.Get("ipbi")
+	}
+	if scheme == "" {
+		scheme = queryParams.Get("ibi")
+	}
+	if !isCustomScheme(scheme) {
+		return ""
+	}
+
+	params := url.Values{}
+	if link := queryParams.Get("link"); link != "" {
+		params.Set("deep_link_id", link)
+	}
+	if minVersion := queryParams.Get("imv"); minVersion != "" {
+		params.Set("imv", minVersion)
+	}
+	appURL := scheme + "://google/link/"
+	if len(params) > 0 {
+		appURL += "?" + params.Encode()
+	}
+	return appURL
+}
+
+// androidAppURL builds an intent:// URL that opens the deep link in the apn package, with Chrome
+// sending users to fallbackURL when the app is missing. It returns "" without apn or deep link.
+// The same URL serves the in-app browser escape page and ANDROID_INTENT_REDIRECT.
+func androidAppURL(queryParams url.Values, fallbackURL string) string {
+	packageName := queryParams.Get("apn")
+	deepLink, err := url.Parse(queryParams.Get("link"))
+	if packageName == "" || err != nil || !deepLink.IsAbs() || deepLink.Host == "" {
+		return ""
+	}
+
+	target := deepLink.Host + deepLink.EscapedPath()
+	if deepLink.RawQuery != "" {
+		target += "?" + deepLink.RawQuery
+	}
+
+	intent := fmt.Sprintf("intent://%s#Intent;scheme=%s;package=%s;", target, deepLink.Scheme, packageName)
+	// Intents cannot require an app version, so amv travels as an extra for the app to check.
+	if minVersion := queryParams.Get("amv"); minVersion != "" {
+		intent += "S.amv=" + url.QueryEscape(minVersion) + ";"
+	}
+	if fallbackURL != "" {
+		intent += "S.browser_fallback_url=" + url.QueryEscape(fallbackURL) + ";"
+	}
+	return intent + "end"
+}
+
+// handleEscapePage serves the interstitial that tries to open the app from an in-app browser and
+// continues to fallbackURL when it does not open.
+func (h *DynamicLinkHandler) handleEscapePage(w http.ResponseWriter, r *http.Request, device utils.Device, appURL, fallbackURL string) {
+	log.Debug().Str("inAppBrowser", device.InAppBrowser).Str("appURL", appURL).Msg("Serving in-app browser escape page")
+
+	if fallbackURL == "" {
+		fallbackURL = h.defaultRedirectURL()
+	}
+
+	tmpl, err := template.ParseFiles("templates/escape.html")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse escape.html template")
+		metrics.TemplateErrors.WithLabelValues("escape.html").Inc()
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tenant := h.service.Tenant(r.Host)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err = tmpl.Execute(w, struct {
+		AppName         string
+		AppIconImageURL string
+		AppURL          template.URL
+		FallbackURL     string
+		TimeoutMillis   int64
+	}{
+		AppName:         tenant.AppName,
+		AppIconImageURL: tenant.AppIconImageURL,
+		// Custom schemes and intent:// are not on html/template's list of safe URL schemes.
+		// iosAppURL and androidAppURL only build URLs with an app scheme or intent://.
+		AppURL:        template.URL(appURL),
+		FallbackURL:   fallbackURL,
+		TimeoutMillis: h.escapeTimeouts.forFamily(device.InAppBrowser).Milliseconds(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to execute template")
+		metrics.TemplateErrors.WithLabelValues("escape.html").Inc()
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+	"dynamic-link-redirect/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// screenParam carries "WIDTHxHEIGHT@SCALE" from the preview page to the store redirect.
+const screenParam = "screen"
+
+const (
+	maxAttributionRequestBytes = 16 << 10
+	maxScreenLength            = 32
+)
+
+type InstallAttributionHandler struct {
+	service *service.InstallAttributionService
+	events  *service.ClickEventPipeline
+	config  *config.Config
+}
+
+// NewInstallAttributionHandler creates the attribution handler. events may be nil; otherwise
+// attributed app launches are emitted as click events for the link stats.
+func NewInstallAttributionHandler(service *service.InstallAttributionService, events *service.ClickEventPipeline, config *config.Config) *InstallAttributionHandler {
+	return &InstallAttributionHandler{service: service, events: events, config: config}
+}
+
+// InstallAttribution serves POST /v1/installAttribution, called by the app on first launch to
+// recover the deep link that led to the install. It requires SHORT_LINKS_API_KEY.
+func (h *InstallAttributionHandler) InstallAttribution(w http.ResponseWriter, r *http.Request) {
+	if h.config.ShortLinksAPIKey == "" ||
+		subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("key")), []byte(h.config.ShortLinksAPIKey)) != 1 {
+		writeAPIError(w, http.StatusForbidden, "PERMISSION_DENIED", "API key not valid")
+		return
+	}
+
+	var req model.InstallAttributionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAttributionRequestBytes)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload")
+		return
+	}
+
+	response, err := h.service.Attribute(r.Context(), &req, clientIP(r))
+	if errors.Is(err, service.ErrInvalidAttributionRequest) {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to attribute install")
+		writeAPIError(w, http.StatusInternalServerError, "INTERNAL", "Internal Server Error")
+		return
+	}
+
+	log.Debug().Str("platform", req.Platform).Str("matchType", response.MatchType).Float64("confidence", response.Confidence).Msg("Attributed install")
+	emitAppEvent(h.events, model.DecisionAppFirstOpen, req.Platform, response)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ReopenAttribution serves POST /v1/reopenAttribution, called by an installed app opened
+// through a short link to resolve its deep link.
+func (h *InstallAttributionHandler) ReopenAttribution(w http.ResponseWriter, r *http.Request) {
+	if h.config.ShortLinksAPIKey == "" ||
+		subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("key")), []byte(h.config.ShortLinksAPIKey)) != 1 {
+		writeAPIError(w, http.StatusForbidden, "PERMISSION_DENIED", "API key not valid")
+		return
+	}
+
+	var req model.ReopenAttributionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAttributionRequestBytes)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload")
+		return
+	}
+
+	response, err := h.service.Reopen(r.Context(), &req)
+	if errors.Is(err, service.ErrInvalidAttributionRequest) {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to attribute reopen")
+		writeAPIError(w, http.StatusInternalServerError, "INTERNAL", "Internal Server Error")
+		return
+	}
+
+	emitAppEvent(h.events, model.DecisionAppReOpen, req.Platform, response)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ExchangeClipboardToken serves POST /v1/exchangeClipboardToken, called by the iOS app with the
+// token the preview page copied to the clipboard.
+func (h *InstallAttributionHandler) ExchangeClipboardToken(w http.ResponseWriter, r *http.Request) {
+	if h.config.ShortLinksAPIKey == "" ||
+		subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("key")), []byte(h.config.ShortLinksAPIKey)) != 1 {
+		writeAPIError(w, http.StatusForbidden, "PERMISSION_DENIED", "API key not valid")
+		return
+	}
+
+	var req model.ExchangeClipboardTokenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAttributionRequestBytes)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload")
+		return
+	}
+
+	response, err := h.service.ExchangeClipboardToken(r.Context(), req.Token)
+	switch {
+	case errors.Is(err, service.ErrInvalidClipboardToken):
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	case errors.Is(err, service.ErrClipboardTokenExpired):
+		writeAPIError(w, http.StatusBadRequest, "FAILED_PRECONDITION", err.Error())
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to exchange clipboard token")
+		writeAPIError(w, http.StatusInternalServerError, "INTERNAL", "Internal Server Error")
+		return
+	}
+
+	// Clipboard handoff only runs on the first launch of the iOS app.
+	emitAppEvent(h.events, model.DecisionAppFirstOpen, "ios", response)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// recordClick stores a store redirect for install attribution and returns its token, or "" when
+// attribution is disabled or recording failed.
+func (h *DynamicLinkHandler) recordClick(r *http.Request, platform string, dynamicLink *url.URL, queryParams url.Values, device utils.Device) string {
+	if h.attribution == nil {
+		return ""
+	}
+
+	shortLink := *dynamicLink
+	shortLink.RawQuery = ""
+	screen := r.URL.Query().Get(screenParam)
+	if len(screen) > maxScreenLength {
+		screen = ""
+	}
+
+	token, err := h.attribution.RecordClick(r.Context(), &model.Click{
+		Platform:    platform,
+		ShortLink:   shortLink.String(),
+		DeepLink:    queryParams.Get("link"),
+		UTMSource:   queryParams.Get("utm_source"),
+		UTMMedium:   queryParams.Get("utm_medium"),
+		UTMCampaign: queryParams.Get("utm_campaign"),
+		IP:          clientIP(r),
+		OSVersion:   device.OSVersion,
+		Screen:      screen,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to record click for install attribution")
+		return ""
+	}
+	return token
+}
+
+// clientIP returns the address of the client; realIP has already applied the forwarding headers
+// of trusted proxies.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
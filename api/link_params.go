@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/url"
+)
+
+// Firebase Dynamic Links long-link parameters and how the redirect flow honors them:
+//
+//	link        deep link; opened on platforms without a more specific target
+//	apn, afl    Android package and fallback link; without afl users go to the Play Store
+//	amv         minimum Android versionCode; passed as the S.amv extra of intent:// URLs. Not
+//	            enforced here: the server cannot see the installed version, so the app checks it
+//	intent      "1" or "0" turns ANDROID_INTENT_REDIRECT on or off for this link (not a Firebase parameter)
+//	ibi, ifl    iOS bundle ID and fallback link; without ifl users go to the App Store (isi). The
+//	            bundle ID is the custom scheme that opens the app when ius is not set
+//	ipbi, ipfl  iPad bundle ID and fallback link; iPads without ipbi use the iPhone app and ifl
+//	isi         App Store ID
+//	ius         iOS custom scheme, tried by the in-app browser escape page
+//	imv         minimum iOS app version; passed on the custom scheme URL. Not enforced here, for
+//	            the same reason as amv
+//	efr         "1" skips the preview page and redirects straight to the app or store
+//	ofl         link for platforms other than Android and iOS
+//	st, sd, si  social title, description and image shown on the preview page
+//	utm_*, gclid forwarded in the Play Store referrer; at, ct, mt, pt appended to the App Store URL
+
+var playStoreReferrerParams = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "gclid"}
+
+func forcedRedirect(queryParams url.Values) bool {
+	efr := queryParams.Get("efr")
+	return efr == "1" || efr == "true"
+}
+
+// intentRedirect reports whether Android users go through an intent:// URL instead of straight to
+// the store. The link's intent parameter overrides the global setting.
+func intentRedirect(queryParams url.Values, enabledGlobally bool) bool {
+	switch queryParams.Get("intent") {
+	case "1", "true":
+		return true
+	case "0", "false":
+		return false
+	}
+	return enabledGlobally
+}
+
+// hasIOSTarget reports whether the link treats iOS differently from other platforms, which is
+// when telling an iPad in desktop mode from a Mac matters.
+func hasIOSTarget(queryParams url.Values) bool {
+	for _, key := range []string{"ibi", "isi", "ifl", "ipbi", "ipfl"} {
+		if queryParams.Get(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// iosFallbackParam returns the name of the fallback link parameter that applies to the device,
+// or "" if the link has none.
+func iosFallbackParam(queryParams url.Values, isiPad bool) string {
+	if isiPad {
+		if queryParams.Get("ipfl") != "" {
+			return "ipfl"
+		}
+		// Without a dedicated iPad app the iPhone app and its fallback are used.
+		if queryParams.Get("ipbi") != "" {
+			return ""
+		}
+	}
+	if queryParams.Get("ifl") != "" {
+		return "ifl"
+	}
+	return ""
+}
+
+// playStoreReferrer builds the escaped referrer value passed to the Play Store: the short link
+// as tracking_id, the install attribution click token if any, and the long link's campaign
+// parameters.
+func playStoreReferrer(dynamicLink *url.URL, queryParams url.Values, clickID string) string {
+	referrer := "tracking_id%3D" + dynamicLink.String()
+	if clickID != "" {
+		referrer += "%26click_id%3D" + clickID
+	}
+
+	campaign := url.Values{}
+	for _, key := range playStoreReferrerParams {
+		if value := queryParams.Get(key); value != "" {
+			campaign.Set(key, value)
+		}
+	}
+	if len(campaign) > 0 {
+		referrer += "%26" + url.QueryEscape(campaign.Encode())
+	}
+	return referrer
+}
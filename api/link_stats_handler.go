@@ -0,0 +1,61 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+type LinkStatsHandler struct {
+	stats  *service.LinkStats
+	config *config.Config
+}
+
+func NewLinkStatsHandler(stats *service.LinkStats, config *config.Config) *LinkStatsHandler {
+	return &LinkStatsHandler{stats: stats, config: config}
+}
+
+// LinkStats serves GET /v1/{shortLink}/linkStats?durationDays=N with the Firebase Dynamic Links
+// Analytics response shape. shortLink is the URL-encoded short link, e.g.
+// /v1/https%3A%2F%2Fexample.page.link%2Fabc/linkStats.
+func (h *LinkStatsHandler) LinkStats(w http.ResponseWriter, r *http.Request) {
+	if h.config.ShortLinksAPIKey == "" ||
+		subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("key")), []byte(h.config.ShortLinksAPIKey)) != 1 {
+		writeAPIError(w, http.StatusForbidden, "PERMISSION_DENIED", "API key not valid")
+		return
+	}
+
+	// chi matches on the escaped path, so the parameter is still encoded.
+	shortLink, err := url.PathUnescape(chi.URLParam(r, "shortLink"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid short link encoding")
+		return
+	}
+	durationDays, err := strconv.Atoi(r.URL.Query().Get("durationDays"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "durationDays must be a number")
+		return
+	}
+
+	stats, err := h.stats.Query(shortLink, durationDays)
+	if errors.Is(err, service.ErrInvalidLinkStatsRequest) {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query link stats")
+		writeAPIError(w, http.StatusInternalServerError, "INTERNAL", "Internal Server Error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, model.LinkStatsResponse{LinkEventStats: stats})
+}
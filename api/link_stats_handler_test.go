@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+)
+
+func TestLinkStats(t *testing.T) {
+	cfg := &config.Config{
+		PreviewUrlStyle:          "hyphenated",
+		ShortLinksAPIKey:         "secret",
+		InstallAttribution:       "true",
+		AttributionWindow:        "1h",
+		AttributionMinConfidence: "0.6",
+		AttributionMaxClicks:     "100",
+	}
+	links := map[string]string{
+		"example.page.link/promo": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpromo&apn=com.example&ofl=https%3A%2F%2Fexample.com%2Fdesktop",
+	}
+	linkStats := service.NewLinkStats(30)
+	pipeline := service.NewClickEventPipeline([]service.ClickEventSink{linkStats}, service.ClickEventOptions{})
+	router := newTestRouterWithEvents(cfg, service.NewMemoryLinkResolver(links), service.NewMemoryLinkStore(), pipeline, linkStats)
+
+	serve := func(method, target, userAgent, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	serve(http.MethodGet, "https://example.page.link/promo", testDesktopUA, "")
+	// A desktop browser on the preview host clicks once, on the preview page.
+	rec := serve(http.MethodGet, "https://preview-example.page.link/promo", testDesktopUA, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("desktop preview status = %d, want the preview page", rec.Code)
+	}
+	serve(http.MethodGet, "https://example.page.link/promo?from-preview=true", testDesktopUA, "")
+	serve(http.MethodGet, "https://preview-example.page.link/promo", testAndroidUA, "")
+	rec = serve(http.MethodGet, "https://example.page.link/promo?from-preview=true", testAndroidUA, "")
+	referrer, _ := url.Parse(rec.Header().Get("Location"))
+	serve(http.MethodPost, "/v1/installAttribution?key=secret", "", `{"platform":"android","installReferrer":"`+referrer.Query().Get("referrer")+`"}`)
+	serve(http.MethodPost, "/v1/reopenAttribution?key=secret", "", `{"platform":"ios","requestedLink":"https://example.page.link/promo"}`)
+
+	// Close flushes the queued events into the stats.
+	if err := pipeline.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	statsPath := "/v1/" + url.PathEscape("https://example.page.link/promo") + "/linkStats"
+	rec = serve(http.MethodGet, statsPath+"?durationDays=7&key=secret", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"count":"1"`) {
+		t.Errorf("body = %s, want counts encoded as strings", rec.Body.String())
+	}
+	var response model.LinkStatsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	want := []model.LinkEventStat{
+		{Platform: "ANDROID", Count: 1, Event: "APP_FIRST_OPEN"},
+		{Platform: "ANDROID", Count: 1, Event: "APP_INSTALL"},
+		{Platform: "ANDROID", Count: 1, Event: "CLICK"},
+		{Platform: "ANDROID", Count: 1, Event: "REDIRECT"},
+		{Platform: "DESKTOP", Count: 2, Event: "CLICK"},
+		{Platform: "DESKTOP", Count: 2, Event: "REDIRECT"},
+		{Platform: "IOS", Count: 1, Event: "APP_RE_OPEN"},
+	}
+	if !reflect.DeepEqual(response.LinkEventStats, want) {
+		t.Errorf("linkEventStats = %+v, want %+v", response.LinkEventStats, want)
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{"missing key", statsPath + "?durationDays=7", http.StatusForbidden},
+		{"missing duration", statsPath + "?key=secret", http.StatusBadRequest},
+		{"zero duration", statsPath + "?durationDays=0&key=secret", http.StatusBadRequest},
+		{"relative link", "/v1/promo/linkStats?durationDays=7&key=secret", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(http.MethodGet, tt.target, "", ""); rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLinkStatsWithoutAPIKey(t *testing.T) {
+	cfg := &config.Config{
+		PreviewUrlStyle:          "hyphenated",
+		InstallAttribution:       "true",
+		AttributionWindow:        "1h",
+		AttributionMinConfidence: "0.6",
+		AttributionMaxClicks:     "100",
+	}
+	linkStats := service.NewLinkStats(30)
+	pipeline := service.NewClickEventPipeline([]service.ClickEventSink{linkStats}, service.ClickEventOptions{})
+	defer pipeline.Close(context.Background())
+	router := newTestRouterWithEvents(cfg, service.NewMemoryLinkResolver(nil), service.NewMemoryLinkStore(), pipeline, linkStats)
+
+	requests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{http.MethodGet, "/v1/" + url.PathEscape("https://example.page.link/promo") + "/linkStats?durationDays=7", ""},
+		{http.MethodPost, "/v1/reopenAttribution", `{"platform":"ios","requestedLink":"https://example.page.link/promo"}`},
+	}
+	for _, request := range requests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(request.method, request.target, strings.NewReader(request.body)))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s status = %d, want %d", request.method, request.target, rec.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// countRequests counts every request by the route pattern it matched, so short codes do not
+// become label values.
+func countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, statusLabel(ww.Status())).Inc()
+	})
+}
+
+// observeRedirect records the outcome and latency of a dynamic link request, and adds the
+// outcome to the request's span.
+func observeRedirect(ctx context.Context, event *model.ClickEvent, ww middleware.WrapResponseWriter, start time.Time) {
+	platform := event.Platform
+	if platform == "" {
+		platform = "unknown"
+	}
+	decision := decisionClass(event)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("dynamic_link.platform", platform),
+		attribute.String("dynamic_link.decision", event.Decision),
+	)
+	metrics.Redirects.WithLabelValues(platform, decision, statusLabel(ww.Status())).Inc()
+	metrics.RedirectDuration.WithLabelValues(platform, decision).Observe(time.Since(start).Seconds())
+}
+
+// decisionClass groups click event decisions into the few classes worth a metric label.
+func decisionClass(event *model.ClickEvent) string {
+	switch event.Decision {
+	case model.DecisionPreviewPage, model.DecisionPreviewRedirect:
+		return "preview"
+	case model.DecisionPlayStore, model.DecisionAppStore:
+		return "store"
+	case model.DecisionIntent, model.DecisionEscapePage:
+		return "app"
+	case "afl", "ifl", "ipfl", "ofl", "link", model.DecisionDefaultRedirect, model.DecisionDefaultPage:
+		if event.Platform == "web" {
+			return "web"
+		}
+		return "fallback"
+	case model.DecisionExpiredRedirect, model.DecisionGonePage:
+		return "expired"
+	case model.DecisionSocialPage:
+		return "social"
+	case model.DecisionPlatformDetection:
+		return "detection"
+	case model.DecisionNotFound:
+		return "not_found"
+	case model.DecisionUnavailable, model.DecisionError:
+		return "error"
+	}
+	return "other"
+}
+
+func statusLabel(status int) string {
+	if status == 0 {
+		// Nothing was written, which net/http answers with 200.
+		status = http.StatusOK
+	}
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	router := newTestRouter(map[string]string{
+		"example.page.link/metrics-web": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&ofl=https%3A%2F%2Fexample.com%2Fdesktop",
+	})
+	for _, target := range []string{"https://example.page.link/metrics-web", "https://example.page.link/metrics-missing"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("User-Agent", testDesktopUA)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://example.page.link/metrics", nil))
+	if strings.Contains(rec.Body.String(), "dynamic_link_redirects_total") {
+		t.Error("metrics are served on the public router")
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://example.page.link/debug/vars", nil))
+	if strings.Contains(rec.Body.String(), "memstats") {
+		t.Error("variables are served on the public router")
+	}
+
+	rec = httptest.NewRecorder()
+	NewAdminRouter(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`dynamic_link_redirects_total{decision="web",platform="web",status="302"}`,
+		`dynamic_link_redirects_total{decision="not_found",platform="unknown",status="404"}`,
+		`dynamic_link_redirect_duration_seconds_count{decision="web",platform="web"}`,
+		`dynamic_link_http_requests_total{method="GET",route="/{shortCode}",status="302"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
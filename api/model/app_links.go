@@ -0,0 +1,46 @@
+package model
+
+const AssetLinkRelationHandleAllURLs = "delegate_permission/common.handle_all_urls"
+
+// AppleAppSiteAssociation is the apple-app-site-association document in the iOS 13+ format, with
+// the keys of the earlier format for older devices.
+type AppleAppSiteAssociation struct {
+	AppLinks       *AppleAppLinks       `json:"applinks,omitempty"`
+	WebCredentials *AppleWebCredentials `json:"webcredentials,omitempty"`
+}
+
+type AppleAppLinks struct {
+	// Apps must be present and empty before iOS 13.
+	Apps    []string              `json:"apps"`
+	Details []AppleAppLinksDetail `json:"details"`
+}
+
+// AppleAppLinksDetail holds either the iOS 13+ keys or the earlier appID and paths.
+type AppleAppLinksDetail struct {
+	AppIDs     []string            `json:"appIDs,omitempty"`
+	Components []AppleURLComponent `json:"components,omitempty"`
+	AppID      string              `json:"appID,omitempty"`
+	Paths      []string            `json:"paths,omitempty"`
+}
+
+// AppleURLComponent matches a URL path; "/" is the key Apple uses for the path pattern.
+type AppleURLComponent struct {
+	Path    string `json:"/"`
+	Exclude bool   `json:"exclude,omitempty"`
+}
+
+type AppleWebCredentials struct {
+	Apps []string `json:"apps"`
+}
+
+// AssetLinkStatement is one entry of a Digital Asset Links assetlinks.json document.
+type AssetLinkStatement struct {
+	Relation []string        `json:"relation"`
+	Target   AssetLinkTarget `json:"target"`
+}
+
+type AssetLinkTarget struct {
+	Namespace              string   `json:"namespace"`
+	PackageName            string   `json:"package_name"`
+	SHA256CertFingerprints []string `json:"sha256_cert_fingerprints"`
+}
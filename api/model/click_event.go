@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Redirect decisions recorded on click events.
+const (
+	DecisionNotFound          = "not_found"
+	DecisionUnavailable       = "unavailable"
+	DecisionError             = "error"
+	DecisionGonePage          = "gone_page"
+	DecisionExpiredRedirect   = "expired_redirect"
+	DecisionSocialPage        = "social_page"
+	DecisionPlatformDetection = "platform_detection"
+	DecisionPreviewPage       = "preview_page"
+	DecisionPreviewRedirect   = "preview_redirect"
+	DecisionEscapePage        = "escape_page"
+	DecisionIntent            = "intent"
+	DecisionPlayStore         = "play_store"
+	DecisionAppStore          = "app_store"
+	DecisionDefaultRedirect   = "default_redirect"
+	DecisionDefaultPage       = "default_page"
+	DecisionAppFirstOpen      = "app_first_open"
+	DecisionAppReOpen         = "app_re_open"
+	// Redirects to one of the link's own URLs use the parameter name: afl, ifl, ipfl, ofl or link.
+)
+
+// ClickEvent describes one request for a dynamic link and what the redirect flow did with it.
+type ClickEvent struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Domain       string    `json:"domain"`
+	ShortCode    string    `json:"shortCode,omitempty"`
+	Platform     string    `json:"platform,omitempty"`
+	InAppBrowser string    `json:"inAppBrowser,omitempty"`
+	Decision     string    `json:"decision"`
+	TargetURL    string    `json:"targetUrl,omitempty"`
+	PreviewShown bool      `json:"previewShown"`
+	UTMSource    string    `json:"utmSource,omitempty"`
+	UTMMedium    string    `json:"utmMedium,omitempty"`
+	UTMCampaign  string    `json:"utmCampaign,omitempty"`
+	UTMTerm      string    `json:"utmTerm,omitempty"`
+	UTMContent   string    `json:"utmContent,omitempty"`
+	Referrer     string    `json:"referrer,omitempty"`
+	Country      string    `json:"country,omitempty"`
+}
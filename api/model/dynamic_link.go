@@ -1,9 +1,105 @@
 package model
 
+import (
+	"strings"
+	"time"
+)
+
 type ExchangeShortLinkRequest struct {
 	RequestedLink string `json:"requestedLink"`
 }
 
 type LongLinkResponseModel struct {
 	LongLink string `json:"longLink"`
+	// NotBefore and ExpiresAt optionally bound when the link may be served.
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// DynamicLink is a short link owned by this service, identified by domain and short code.
+type DynamicLink struct {
+	Domain    string     `json:"domain"`
+	ShortCode string     `json:"shortCode"`
+	LongLink  string     `json:"longLink"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type LinkFilter struct {
+	Domain        string
+	Prefix        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	PageSize      int
+	PageToken     string
+}
+
+func (f LinkFilter) Matches(link *DynamicLink) bool {
+	if f.Domain != "" && !strings.EqualFold(link.Domain, f.Domain) {
+		return false
+	}
+	if f.Prefix != "" && !strings.HasPrefix(link.ShortCode, f.Prefix) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && link.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !link.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+type ListLinksResponse struct {
+	Links         []DynamicLink `json:"links"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
+}
+
+type PutLinkRequest struct {
+	LongLink  string     `json:"longLink"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// LinkPatch updates individual long link parameters. A nil field is left unchanged and an
+// empty string removes the parameter.
+type LinkPatch struct {
+	Link *string `json:"link,omitempty"`
+	IFL  *string `json:"ifl,omitempty"`
+	AFL  *string `json:"afl,omitempty"`
+	OFL  *string `json:"ofl,omitempty"`
+	ST   *string `json:"st,omitempty"`
+	SD   *string `json:"sd,omitempty"`
+	SI   *string `json:"si,omitempty"`
+	// NotBefore and ExpiresAt take RFC 3339 timestamps.
+	NotBefore *string `json:"notBefore,omitempty"`
+	ExpiresAt *string `json:"expiresAt,omitempty"`
+}
+
+type ImportReport struct {
+	// LastRow is the number of data rows consumed; pass it as skipRows to resume.
+	LastRow    int64         `json:"lastRow"`
+	Imported   int64         `json:"imported"`
+	Duplicates int64         `json:"duplicates"`
+	Conflicts  int64         `json:"conflicts"`
+	Invalid    int64         `json:"invalid"`
+	Issues     []ImportIssue `json:"issues,omitempty"`
+	// IssuesTruncated is set once more issues occurred than are listed.
+	IssuesTruncated bool `json:"issuesTruncated,omitempty"`
+}
+
+type ImportIssue struct {
+	Row       int64  `json:"row"`
+	ShortLink string `json:"shortLink"`
+	Reason    string `json:"reason"`
+}
+
+func (r *ImportReport) AddIssue(issue ImportIssue, limit int) {
+	if len(r.Issues) >= limit {
+		r.IssuesTruncated = true
+		return
+	}
+	r.Issues = append(r.Issues, issue)
 }
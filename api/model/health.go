@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusFailing  = "failing"
+	HealthStatusDraining = "draining"
+)
+
+// HealthCheckResult is the outcome of one readiness check.
+type HealthCheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// HealthStatus is the detailed status served to operators. Status is ok only when every check
+// that is not optional passes and the server is not draining for shutdown.
+type HealthStatus struct {
+	Status    string              `json:"status"`
+	Draining  bool                `json:"draining"`
+	StartedAt time.Time           `json:"startedAt"`
+	Uptime    string              `json:"uptime"`
+	CheckedAt time.Time           `json:"checkedAt"`
+	Checks    []HealthCheckResult `json:"checks"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// Click is a redirect to an app store, kept for a while so the app can recover the deep link
+// on its first launch after install.
+type Click struct {
+	Token       string `json:"token"`
+	Platform    string `json:"platform"`
+	ShortLink   string `json:"shortLink"`
+	DeepLink    string `json:"deepLink"`
+	UTMSource   string `json:"utmSource,omitempty"`
+	UTMMedium   string `json:"utmMedium,omitempty"`
+	UTMCampaign string `json:"utmCampaign,omitempty"`
+	IP          string `json:"ip"`
+	OSVersion   string `json:"osVersion,omitempty"`
+	// Screen is "WIDTHxHEIGHT@SCALE" in points, as reported by the preview page.
+	Screen    string    `json:"screen,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// InstallAttributionRequest is sent by the app on first launch.
+type InstallAttributionRequest struct {
+	// Platform is "android" or "ios".
+	Platform string `json:"platform"`
+	// InstallReferrer is the referrer string from the Play Install Referrer API.
+	InstallReferrer string `json:"installReferrer,omitempty"`
+	OSVersion       string `json:"osVersion,omitempty"`
+	// Screen is "WIDTHxHEIGHT@SCALE" in points, e.g. "390x844@3".
+	Screen string `json:"screen,omitempty"`
+}
+
+// Install attribution match types.
+const (
+	MatchUnique  = "UNIQUE"
+	MatchDefault = "DEFAULT"
+	MatchNone    = "NONE"
+)
+
+type InstallAttributionResponse struct {
+	DeepLink      string  `json:"deepLink,omitempty"`
+	RequestedLink string  `json:"requestedLink,omitempty"`
+	MatchType     string  `json:"matchType"`
+	Confidence    float64 `json:"confidence"`
+	UTMSource     string  `json:"utmSource,omitempty"`
+	UTMMedium     string  `json:"utmMedium,omitempty"`
+	UTMCampaign   string  `json:"utmCampaign,omitempty"`
+}
+
+// ExchangeClipboardTokenRequest carries the token the preview page copied to the clipboard.
+type ExchangeClipboardTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// ReopenAttributionRequest is sent by an installed app opened through a short link.
+type ReopenAttributionRequest struct {
+	// Platform is "android" or "ios".
+	Platform      string `json:"platform"`
+	RequestedLink string `json:"requestedLink"`
+}
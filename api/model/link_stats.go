@@ -0,0 +1,26 @@
+package model
+
+// Link stats platforms and event types, as in the Firebase Dynamic Links Analytics API.
+const (
+	StatsPlatformAndroid = "ANDROID"
+	StatsPlatformIOS     = "IOS"
+	StatsPlatformDesktop = "DESKTOP"
+	StatsPlatformOther   = "OTHER"
+
+	StatsEventClick        = "CLICK"
+	StatsEventRedirect     = "REDIRECT"
+	StatsEventAppInstall   = "APP_INSTALL"
+	StatsEventAppFirstOpen = "APP_FIRST_OPEN"
+	StatsEventAppReOpen    = "APP_RE_OPEN"
+)
+
+type LinkEventStat struct {
+	Platform string `json:"platform"`
+	Count    int64  `json:"count,string"`
+	Event    string `json:"event"`
+}
+
+// LinkStatsResponse is the body of GET /v1/{shortLink}/linkStats.
+type LinkStatsResponse struct {
+	LinkEventStats []LinkEventStat `json:"linkEventStats"`
+}
@@ -0,0 +1,90 @@
+package model
+
+// The types below mirror the Firebase Dynamic Links REST API (POST /v1/shortLinks).
+
+type CreateShortLinkRequest struct {
+	LongDynamicLink string           `json:"longDynamicLink,omitempty"`
+	DynamicLinkInfo *DynamicLinkInfo `json:"dynamicLinkInfo,omitempty"`
+	Suffix          *Suffix          `json:"suffix,omitempty"`
+}
+
+type Suffix struct {
+	Option string `json:"option"`
+}
+
+type DynamicLinkInfo struct {
+	DomainURIPrefix   string             `json:"domainUriPrefix"`
+	Link              string             `json:"link"`
+	AndroidInfo       *AndroidInfo       `json:"androidInfo,omitempty"`
+	IOSInfo           *IOSInfo           `json:"iosInfo,omitempty"`
+	NavigationInfo    *NavigationInfo    `json:"navigationInfo,omitempty"`
+	AnalyticsInfo     *AnalyticsInfo     `json:"analyticsInfo,omitempty"`
+	SocialMetaTagInfo *SocialMetaTagInfo `json:"socialMetaTagInfo,omitempty"`
+	DesktopInfo       *DesktopInfo       `json:"desktopInfo,omitempty"`
+}
+
+type AndroidInfo struct {
+	AndroidPackageName           string `json:"androidPackageName,omitempty"`
+	AndroidFallbackLink          string `json:"androidFallbackLink,omitempty"`
+	AndroidMinPackageVersionCode string `json:"androidMinPackageVersionCode,omitempty"`
+}
+
+type IOSInfo struct {
+	IOSBundleID         string `json:"iosBundleId,omitempty"`
+	IOSFallbackLink     string `json:"iosFallbackLink,omitempty"`
+	IOSCustomScheme     string `json:"iosCustomScheme,omitempty"`
+	IOSIpadFallbackLink string `json:"iosIpadFallbackLink,omitempty"`
+	IOSIpadBundleID     string `json:"iosIpadBundleId,omitempty"`
+	IOSAppStoreID       string `json:"iosAppStoreId,omitempty"`
+	IOSMinimumVersion   string `json:"iosMinimumVersion,omitempty"`
+}
+
+type NavigationInfo struct {
+	EnableForcedRedirect bool `json:"enableForcedRedirect,omitempty"`
+}
+
+type AnalyticsInfo struct {
+	GooglePlayAnalytics    *GooglePlayAnalytics    `json:"googlePlayAnalytics,omitempty"`
+	ItunesConnectAnalytics *ItunesConnectAnalytics `json:"itunesConnectAnalytics,omitempty"`
+}
+
+type GooglePlayAnalytics struct {
+	UTMSource   string `json:"utmSource,omitempty"`
+	UTMMedium   string `json:"utmMedium,omitempty"`
+	UTMCampaign string `json:"utmCampaign,omitempty"`
+	UTMTerm     string `json:"utmTerm,omitempty"`
+	UTMContent  string `json:"utmContent,omitempty"`
+	Gclid       string `json:"gclid,omitempty"`
+}
+
+type ItunesConnectAnalytics struct {
+	At string `json:"at,omitempty"`
+	Ct string `json:"ct,omitempty"`
+	Mt string `json:"mt,omitempty"`
+	Pt string `json:"pt,omitempty"`
+}
+
+type SocialMetaTagInfo struct {
+	SocialTitle       string `json:"socialTitle,omitempty"`
+	SocialDescription string `json:"socialDescription,omitempty"`
+	SocialImageLink   string `json:"socialImageLink,omitempty"`
+}
+
+type DesktopInfo struct {
+	DesktopFallbackLink string `json:"desktopFallbackLink,omitempty"`
+}
+
+type CreateShortLinkResponse struct {
+	ShortLink   string `json:"shortLink"`
+	PreviewLink string `json:"previewLink"`
+}
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
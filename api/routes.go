@@ -1,68 +1,143 @@
 package api
 
 import (
-    "strings"
+	"expvar"
 	"net/http"
 
 	"dynamic-link-redirect/api/service"
 	"dynamic-link-redirect/config"
+	"dynamic-link-redirect/metrics"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
-func NewRouter(cfg *config.Config) *chi.Mux {
+// RouterOptions are the optional services of the public router. A nil service turns off the
+// routes it backs.
+type RouterOptions struct {
+	// Attribution serves install and reopen attribution.
+	Attribution *service.InstallAttributionService
+	// ClickEvents receives an event per redirect.
+	ClickEvents *service.ClickEventPipeline
+	// LinkStats serves the linkStats API.
+	LinkStats *service.LinkStats
+	// Health serves the readiness probe; without it only the liveness probe is served.
+	Health *service.HealthService
+	// AppLinks generates the .well-known documents; without it only the override files are served.
+	AppLinks *service.AppLinks
+}
+
+// NewRouter wires the HTTP routes.
+func NewRouter(cfg *config.Config, linkService *service.DynamicLinkService, shortLinkService *service.ShortLinkService, options RouterOptions) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
+	r.Use(realIP(parseTrustedProxies(cfg.TrustedProxies)))
+	r.Use(traceRequests)
+	r.Use(countRequests)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	linkService := service.NewDynamicLinkService(cfg)
-	handler := NewDynamicLinkHandler(linkService, cfg)
-
-	r.Get("/.well-known/apple-app-site-association", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Expires", "0")
-		http.ServeFile(w, r, "static/apple-app-site-association.json")
-	})
-
-	r.Head("/.well-known/apple-app-site-association", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-	})
+	handler := NewDynamicLinkHandler(linkService, options.Attribution, options.ClickEvents, cfg)
+	shortLinkHandler := NewShortLinkHandler(shortLinkService, cfg)
 
-	r.Get("/.well-known/assetlinks.json", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		http.ServeFile(w, r, "static/assetlinks.json")
-	})
+	appLinksHandler := NewAppLinksHandler(options.AppLinks, linkService)
+	r.Get("/.well-known/apple-app-site-association", appLinksHandler.AppleAppSiteAssociation)
+	r.Head("/.well-known/apple-app-site-association", appLinksHandler.AppleAppSiteAssociation)
+	r.Get("/.well-known/assetlinks.json", appLinksHandler.AssetLinks)
+	r.Head("/.well-known/assetlinks.json", appLinksHandler.AssetLinks)
 
-	r.Head("/.well-known/assetlinks.json", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-	})
+	r.Get(healthzPath, Healthz)
+	if options.Health != nil {
+		healthHandler := NewHealthHandler(options.Health)
+		r.Get(readyzPath, healthHandler.Readyz)
+		// The detailed status names backends and errors, so it moves to the admin port with the
+		// variables.
+		if cfg.AdminPort == "" {
+			r.Get(statusPath, healthHandler.Status)
+		}
+	}
 
 	r.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
 	})
 
+	// The domain root with a link parameter is a long dynamic link; anything else at the root
+	// gets the same treatment as an unknown path.
+	r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+		if !service.IsLongDynamicLink(req.URL) {
+			r.NotFoundHandler().ServeHTTP(w, req)
+			return
+		}
+		handler.HandleRedirect(w, req)
+	})
 	r.Get("/{shortCode}", handler.HandleRedirect)
 
+	// Without an API key anyone could mint links on our domains.
+	if cfg.ShortLinksAPIKey != "" {
+		r.Post("/v1/shortLinks", shortLinkHandler.CreateShortLink)
+	}
+	if options.Attribution != nil {
+		attributionHandler := NewInstallAttributionHandler(options.Attribution, options.ClickEvents, cfg)
+		if cfg.ShortLinksAPIKey != "" {
+			r.Post("/v1/installAttribution", attributionHandler.InstallAttribution)
+			r.Post("/v1/reopenAttribution", attributionHandler.ReopenAttribution)
+			if options.Attribution.ClipboardHandoffEnabled() {
+				r.Post("/v1/exchangeClipboardToken", attributionHandler.ExchangeClipboardToken)
+			}
+		}
+	}
+
+	// The counts are not public.
+	if options.LinkStats != nil && cfg.ShortLinksAPIKey != "" {
+		r.Get("/v1/{shortLink}/linkStats", NewLinkStatsHandler(options.LinkStats, cfg).LinkStats)
+	}
+
+	if cfg.AdminAPIToken != "" {
+		adminHandler := NewAdminHandler(shortLinkService, linkService, cfg)
+		r.Route("/admin/links", func(r chi.Router) {
+			r.Use(adminHandler.RequireToken)
+			r.Get("/", adminHandler.ListLinks)
+			r.Get("/{shortCode}", adminHandler.GetLink)
+			r.Put("/{shortCode}", adminHandler.PutLink)
+			r.Patch("/{shortCode}", adminHandler.PatchLink)
+			r.Delete("/{shortCode}", adminHandler.DeleteLink)
+		})
+		r.With(adminHandler.RequireToken).Post("/admin/import", adminHandler.ImportLinks)
+	}
+
 	fs := http.FileServer(http.Dir("static"))
 	r.Handle("/static/*", http.StripPrefix("/static/", fs))
 
-    if cfg.EnableFallback == "true" && strings.TrimSpace(cfg.FallbackHost) != "" {
-        r.NotFound(domainFallbackRedirect(cfg))
+    if cfg.EnableFallback == "true" {
+        r.NotFound(domainFallbackRedirect(linkService))
     }
 
 	return r
 }
+
+// NewAdminRouter serves the operational endpoints on ADMIN_PORT, which is not reachable through
+// the public link domains. health may be nil.
+func NewAdminRouter(health *service.HealthService) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+
+	r.Handle("/metrics", metrics.Handler())
+	r.Handle("/debug/vars", expvar.Handler())
+
+	r.Get(healthzPath, Healthz)
+	if health != nil {
+		healthHandler := NewHealthHandler(health)
+		r.Get(readyzPath, healthHandler.Readyz)
+		r.Get(statusPath, healthHandler.Status)
+	}
+
+	return r
+}
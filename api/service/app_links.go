@@ -0,0 +1,185 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+// ErrInvalidAppLinks wraps app link settings that Apple or Google would reject.
+var ErrInvalidAppLinks = errors.New("invalid app links")
+
+var (
+	appleTeamIDPattern       = regexp.MustCompile(`^[A-Z0-9]{10}$`)
+	appleBundleIDPattern     = regexp.MustCompile(`^[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+	androidPackagePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)+$`)
+	sha256FingerprintPattern = regexp.MustCompile(`^[0-9A-F]{2}(:[0-9A-F]{2}){31}$`)
+)
+
+// AppLinks serves the apple-app-site-association and assetlinks.json documents of each tenant.
+// They are generated and validated once, when it is created.
+type AppLinks struct {
+	tenants                  *config.Tenants
+	appleAppSiteAssociations map[*config.Tenant][]byte
+	assetLinks               map[*config.Tenant][]byte
+}
+
+func NewAppLinks(tenants *config.Tenants) (*AppLinks, error) {
+	a := &AppLinks{
+		tenants:                  tenants,
+		appleAppSiteAssociations: make(map[*config.Tenant][]byte),
+		assetLinks:               make(map[*config.Tenant][]byte),
+	}
+	for _, tenant := range tenants.All() {
+		if err := checkOverrideFile(tenant.AppleAppSiteAssociationFile, &model.AppleAppSiteAssociation{}); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if err := checkOverrideFile(tenant.AssetLinksFile, &[]model.AssetLinkStatement{}); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if tenant.AppLinks == nil {
+			continue
+		}
+
+		aasa, err := appleAppSiteAssociation(tenant.AppLinks)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if aasa != nil {
+			if a.appleAppSiteAssociations[tenant], err = json.Marshal(aasa); err != nil {
+				return nil, err
+			}
+		}
+
+		statements, err := assetLinkStatements(tenant.AppLinks)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if statements != nil {
+			if a.assetLinks[tenant], err = json.Marshal(statements); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// checkOverrideFile makes sure an override file, if set, is readable and decodes into document.
+// The file is read again on every request so it can be replaced without a restart.
+func checkOverrideFile(path string, document any) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read app links override: %w", err)
+	}
+	if err := json.Unmarshal(data, document); err != nil {
+		return fmt.Errorf("%w: override %s: %v", ErrInvalidAppLinks, path, err)
+	}
+	return nil
+}
+
+// AppleAppSiteAssociation returns the document for host, or nil when its tenant has no iOS app.
+func (a *AppLinks) AppleAppSiteAssociation(host string) []byte {
+	return a.appleAppSiteAssociations[a.tenants.ForHost(host)]
+}
+
+// AssetLinks returns the document for host, or nil when its tenant has no Android app.
+func (a *AppLinks) AssetLinks(host string) []byte {
+	return a.assetLinks[a.tenants.ForHost(host)]
+}
+
+// appleAppSiteAssociation builds the document for links, or nil without bundle IDs.
+func appleAppSiteAssociation(links *config.AppLinks) (*model.AppleAppSiteAssociation, error) {
+	if len(links.AppleBundleIDs) == 0 {
+		if links.AppleWebCredentials {
+			return nil, fmt.Errorf("%w: web credentials require bundle IDs", ErrInvalidAppLinks)
+		}
+		return nil, nil
+	}
+	if !appleTeamIDPattern.MatchString(links.AppleTeamID) {
+		return nil, fmt.Errorf("%w: team ID must be 10 uppercase letters or digits: %q", ErrInvalidAppLinks, links.AppleTeamID)
+	}
+
+	appIDs := make([]string, 0, len(links.AppleBundleIDs))
+	for _, bundleID := range links.AppleBundleIDs {
+		if !appleBundleIDPattern.MatchString(bundleID) {
+			return nil, fmt.Errorf("%w: invalid bundle ID: %q", ErrInvalidAppLinks, bundleID)
+		}
+		appIDs = append(appIDs, links.AppleTeamID+"."+bundleID)
+	}
+
+	paths := links.ApplePaths
+	if len(paths) == 0 {
+		paths = []string{"/*"}
+	}
+	components := make([]model.AppleURLComponent, 0, len(paths))
+	legacyPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		pattern, exclude := strings.CutPrefix(path, "!")
+		if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "*") {
+			return nil, fmt.Errorf("%w: path pattern must start with / or *: %q", ErrInvalidAppLinks, path)
+		}
+		components = append(components, model.AppleURLComponent{Path: pattern, Exclude: exclude})
+		if exclude {
+			pattern = "NOT " + pattern
+		}
+		legacyPaths = append(legacyPaths, pattern)
+	}
+
+	// iOS 13 and later use the first detail; earlier versions only read the appID ones.
+	details := []model.AppleAppLinksDetail{{AppIDs: appIDs, Components: components}}
+	for _, appID := range appIDs {
+		details = append(details, model.AppleAppLinksDetail{AppID: appID, Paths: legacyPaths})
+	}
+	aasa := &model.AppleAppSiteAssociation{
+		AppLinks: &model.AppleAppLinks{Apps: []string{}, Details: details},
+	}
+	if links.AppleWebCredentials {
+		aasa.WebCredentials = &model.AppleWebCredentials{Apps: appIDs}
+	}
+	return aasa, nil
+}
+
+// assetLinkStatements builds one statement per package, or nil without packages. Every package
+// accepts every fingerprint, since the apps are usually signed by the same keys.
+func assetLinkStatements(links *config.AppLinks) ([]model.AssetLinkStatement, error) {
+	if len(links.AndroidPackageNames) == 0 {
+		return nil, nil
+	}
+	if len(links.AndroidSHA256Fingerprints) == 0 {
+		return nil, fmt.Errorf("%w: Android packages require SHA-256 certificate fingerprints", ErrInvalidAppLinks)
+	}
+
+	fingerprints := make([]string, 0, len(links.AndroidSHA256Fingerprints))
+	for _, fingerprint := range links.AndroidSHA256Fingerprints {
+		fingerprint = strings.ToUpper(fingerprint)
+		if !sha256FingerprintPattern.MatchString(fingerprint) {
+			return nil, fmt.Errorf("%w: SHA-256 fingerprint must be 32 colon separated hex bytes: %q", ErrInvalidAppLinks, fingerprint)
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	statements := make([]model.AssetLinkStatement, 0, len(links.AndroidPackageNames))
+	for _, packageName := range links.AndroidPackageNames {
+		if !androidPackagePattern.MatchString(packageName) {
+			return nil, fmt.Errorf("%w: invalid package name: %q", ErrInvalidAppLinks, packageName)
+		}
+		statements = append(statements, model.AssetLinkStatement{
+			Relation: []string{model.AssetLinkRelationHandleAllURLs},
+			Target: model.AssetLinkTarget{
+				Namespace:              "android_app",
+				PackageName:            packageName,
+				SHA256CertFingerprints: fingerprints,
+			},
+		})
+	}
+	return statements, nil
+}
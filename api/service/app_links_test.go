@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dynamic-link-redirect/config"
+)
+
+const testFingerprint = "14:6D:E9:83:C5:73:06:50:D8:EE:B9:95:2F:34:FC:64:16:A0:83:42:E6:1D:BE:A8:8A:04:96:B2:3F:CF:44:E5"
+
+func TestAppLinks(t *testing.T) {
+	cfg := &config.Config{
+		AppleTeamID:               "ABCDE12345",
+		AppleBundleIDs:            "com.example.app,com.example.beta",
+		AppleAppLinkPaths:         "!/static/*,/*",
+		AppleWebCredentials:       "true",
+		AndroidPackageNames:       "com.example.app",
+		AndroidSHA256Fingerprints: "14:6d:e9:83:c5:73:06:50:d8:ee:b9:95:2f:34:fc:64:16:a0:83:42:e6:1d:be:a8:8a:04:96:b2:3f:cf:44:e5",
+		TenantsFile:               filepath.Join(t.TempDir(), "tenants.json"),
+	}
+	if err := os.WriteFile(cfg.TenantsFile, []byte(`[{"name": "partner", "hosts": ["partner.page.link"], "appLinks": {"androidPackageNames": ["com.partner"], "androidSha256CertFingerprints": ["`+testFingerprint+`"]}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appLinks, err := NewAppLinks(tenants)
+	if err != nil {
+		t.Fatalf("NewAppLinks() error = %v", err)
+	}
+
+	wantAASA := `{"applinks":{"apps":[],"details":[{"appIDs":["ABCDE12345.com.example.app","ABCDE12345.com.example.beta"],"components":[{"/":"/static/*","exclude":true},{"/":"/*"}]},` +
+		`{"appID":"ABCDE12345.com.example.app","paths":["NOT /static/*","/*"]},{"appID":"ABCDE12345.com.example.beta","paths":["NOT /static/*","/*"]}]},` +
+		`"webcredentials":{"apps":["ABCDE12345.com.example.app","ABCDE12345.com.example.beta"]}}`
+	if got := string(appLinks.AppleAppSiteAssociation("example.page.link")); got != wantAASA {
+		t.Errorf("AppleAppSiteAssociation() = %s, want %s", got, wantAASA)
+	}
+	wantAssetLinks := `[{"relation":["delegate_permission/common.handle_all_urls"],"target":{"namespace":"android_app","package_name":"com.example.app","sha256_cert_fingerprints":["` + testFingerprint + `"]}}]`
+	if got := string(appLinks.AssetLinks("preview-example.page.link")); got != wantAssetLinks {
+		t.Errorf("AssetLinks() = %s, want %s", got, wantAssetLinks)
+	}
+
+	// The partner tenant has an Android app only.
+	if got := appLinks.AppleAppSiteAssociation("partner.page.link"); got != nil {
+		t.Errorf("partner AppleAppSiteAssociation() = %s, want nil", got)
+	}
+	wantPartner := `[{"relation":["delegate_permission/common.handle_all_urls"],"target":{"namespace":"android_app","package_name":"com.partner","sha256_cert_fingerprints":["` + testFingerprint + `"]}}]`
+	if got := string(appLinks.AssetLinks("partner.page.link")); got != wantPartner {
+		t.Errorf("partner AssetLinks() = %s, want %s", got, wantPartner)
+	}
+}
+
+func TestAppLinksInvalid(t *testing.T) {
+	overrideDir := t.TempDir()
+	invalidOverride := filepath.Join(overrideDir, "assetlinks.json")
+	if err := os.WriteFile(invalidOverride, []byte(`{"not": "an array"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{name: "lowercase team ID", cfg: config.Config{AppleTeamID: "abcde12345", AppleBundleIDs: "com.example.app"}},
+		{name: "missing team ID", cfg: config.Config{AppleBundleIDs: "com.example.app"}},
+		{name: "invalid bundle ID", cfg: config.Config{AppleTeamID: "ABCDE12345", AppleBundleIDs: "com example"}},
+		{name: "relative path", cfg: config.Config{AppleTeamID: "ABCDE12345", AppleBundleIDs: "com.example.app", AppleAppLinkPaths: "links/*"}},
+		{name: "web credentials without apps", cfg: config.Config{AppleWebCredentials: "true"}},
+		{name: "invalid package name", cfg: config.Config{AndroidPackageNames: "1example", AndroidSHA256Fingerprints: testFingerprint}},
+		{name: "package without fingerprint", cfg: config.Config{AndroidPackageNames: "com.example.app"}},
+		{name: "SHA-1 fingerprint", cfg: config.Config{AndroidPackageNames: "com.example.app", AndroidSHA256Fingerprints: "14:6D:E9:83:C5:73:06:50:D8:EE:B9:95:2F:34:FC:64:16:A0:83:42"}},
+		{name: "override of the wrong shape", cfg: config.Config{AssetLinksFile: invalidOverride}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAppLinks(config.NewTenants(&tt.cfg))
+			if !errors.Is(err, ErrInvalidAppLinks) {
+				t.Errorf("NewAppLinks() error = %v, want ErrInvalidAppLinks", err)
+			}
+		})
+	}
+
+	missing := config.Config{AssetLinksFile: filepath.Join(overrideDir, "missing.json")}
+	if _, err := NewAppLinks(config.NewTenants(&missing)); err == nil {
+		t.Error("expected an error for a missing override file")
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+type CacheOptions struct {
+	Size int
+	// TTL is how long a resolved link is served without asking the backend again.
+	TTL time.Duration
+	// NegativeTTL is how long an unknown link (empty longLink) is remembered.
+	NegativeTTL time.Duration
+	// StaleTTL is how long past its TTL an entry may still be served while it is refreshed in the background.
+	StaleTTL time.Duration
+}
+
+type CacheStats struct {
+	Size         int    `json:"size"`
+	Hits         uint64 `json:"hits"`
+	Misses       uint64 `json:"misses"`
+	StaleHits    uint64 `json:"staleHits"`
+	NegativeHits uint64 `json:"negativeHits"`
+	Evictions    uint64 `json:"evictions"`
+}
+
+type cacheEntry struct {
+	key        string
+	response   *model.LongLinkResponseModel
+	expiresAt  time.Time
+	staleUntil time.Time
+}
+
+// CachingLinkResolver is a bounded LRU cache in front of another LinkResolver.
+type CachingLinkResolver struct {
+	next    LinkResolver
+	options CacheOptions
+	now     func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+	refreshing map[string]bool
+	// epoch counts invalidations. A lookup that raced one does not cache its answer, which may
+	// predate the change.
+	epoch uint64
+
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	staleHits    atomic.Uint64
+	negativeHits atomic.Uint64
+	evictions    atomic.Uint64
+}
+
+func NewCachingLinkResolver(next LinkResolver, options CacheOptions) *CachingLinkResolver {
+	return &CachingLinkResolver{
+		next:       next,
+		options:    options,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		refreshing: make(map[string]bool),
+	}
+}
+
+func (c *CachingLinkResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	key := linkKey(requestedLink)
+	now := c.now()
+
+	c.mu.Lock()
+	epoch := c.epoch
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if now.Before(entry.expiresAt) {
+			c.order.MoveToFront(element)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			if entry.response == nil {
+				c.negativeHits.Add(1)
+			}
+			return entry.response, nil
+		}
+		if now.Before(entry.staleUntil) {
+			c.order.MoveToFront(element)
+			startRefresh := !c.refreshing[key]
+			c.refreshing[key] = true
+			c.mu.Unlock()
+			c.staleHits.Add(1)
+			if startRefresh {
+				go c.refresh(context.WithoutCancel(ctx), key, requestedLink, epoch)
+			}
+			return entry.response, nil
+		}
+	}
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	response, err := c.next.Resolve(ctx, requestedLink)
+	if err != nil {
+		return nil, err
+	}
+	return c.store(key, response, epoch), nil
+}
+
+func (c *CachingLinkResolver) refresh(ctx context.Context, key string, requestedLink *url.URL, epoch uint64) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.refreshing, key)
+		c.mu.Unlock()
+	}()
+
+	response, err := c.next.Resolve(ctx, requestedLink)
+	if err != nil {
+		log.Warn().Err(err).Str("link", key).Msg("Failed to refresh cached link, serving stale entry")
+		return
+	}
+	c.store(key, response, epoch)
+}
+
+// store caches response unless the cache was invalidated since epoch was read.
+func (c *CachingLinkResolver) store(key string, response *model.LongLinkResponseModel, epoch uint64) *model.LongLinkResponseModel {
+	ttl := c.options.TTL
+	if response == nil || response.LongLink == "" {
+		response = nil
+		ttl = c.options.NegativeTTL
+	}
+	if ttl <= 0 {
+		return response
+	}
+
+	now := c.now()
+	entry := &cacheEntry{
+		key:        key,
+		response:   response,
+		expiresAt:  now.Add(ttl),
+		staleUntil: now.Add(ttl + c.options.StaleTTL),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.epoch != epoch {
+		return response
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return response
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.options.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions.Add(1)
+	}
+	return response
+}
+
+// Invalidate drops any cached resolution for the given short link.
+func (c *CachingLinkResolver) Invalidate(requestedLink *url.URL) {
+	key := linkKey(requestedLink)
+	c.mu.Lock()
+	c.epoch++
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+}
+
+func (c *CachingLinkResolver) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Size:         size,
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		StaleHits:    c.staleHits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Evictions:    c.evictions.Load(),
+	}
+}
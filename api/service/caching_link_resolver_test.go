@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+type countingResolver struct {
+	mu       sync.Mutex
+	calls    int
+	longLink string
+	resolved chan struct{}
+}
+
+func (r *countingResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	r.mu.Lock()
+	r.calls++
+	longLink := r.longLink
+	r.mu.Unlock()
+	if r.resolved != nil {
+		defer func() { r.resolved <- struct{}{} }()
+	}
+	return &model.LongLinkResponseModel{LongLink: longLink}, nil
+}
+
+func (r *countingResolver) callCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	}
+	return parsed
+}
+
+func TestCachingLinkResolver(t *testing.T) {
+	next := &countingResolver{longLink: "https://example.page.link/?link=https://example.com"}
+	cache := NewCachingLinkResolver(next, CacheOptions{Size: 10, TTL: time.Minute, NegativeTTL: time.Second})
+	link := mustParseURL(t, "https://example.page.link/abc")
+
+	for i := 0; i < 3; i++ {
+		response, err := cache.Resolve(context.Background(), link)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response == nil || response.LongLink != next.longLink {
+			t.Fatalf("Resolve() = %v, want %v", response, next.longLink)
+		}
+	}
+
+	if next.callCount() != 1 {
+		t.Errorf("backend calls = %d, want 1", next.callCount())
+	}
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 2 hits and 1 miss", stats)
+	}
+
+	cache.Invalidate(link)
+	if _, err := cache.Resolve(context.Background(), link); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if next.callCount() != 2 {
+		t.Errorf("backend calls after invalidate = %d, want 2", next.callCount())
+	}
+}
+
+func TestCachingLinkResolverNegative(t *testing.T) {
+	next := &countingResolver{}
+	cache := NewCachingLinkResolver(next, CacheOptions{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+	link := mustParseURL(t, "https://example.page.link/missing")
+
+	for i := 0; i < 2; i++ {
+		response, err := cache.Resolve(context.Background(), link)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response != nil {
+			t.Errorf("Resolve() = %v, want nil", response)
+		}
+	}
+
+	if next.callCount() != 1 {
+		t.Errorf("backend calls = %d, want 1", next.callCount())
+	}
+	if stats := cache.Stats(); stats.NegativeHits != 1 {
+		t.Errorf("NegativeHits = %d, want 1", stats.NegativeHits)
+	}
+}
+
+func TestCachingLinkResolverStaleWhileRevalidate(t *testing.T) {
+	next := &countingResolver{longLink: "https://example.page.link/?link=https://old.example.com", resolved: make(chan struct{}, 2)}
+	cache := NewCachingLinkResolver(next, CacheOptions{Size: 10, TTL: time.Minute, StaleTTL: time.Hour})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	link := mustParseURL(t, "https://example.page.link/abc")
+
+	if _, err := cache.Resolve(context.Background(), link); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	<-next.resolved
+
+	next.mu.Lock()
+	next.longLink = "https://example.page.link/?link=https://new.example.com"
+	next.mu.Unlock()
+	now = now.Add(2 * time.Minute)
+
+	response, err := cache.Resolve(context.Background(), link)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.LongLink != "https://example.page.link/?link=https://old.example.com" {
+		t.Errorf("Resolve() = %v, want stale entry", response.LongLink)
+	}
+
+	select {
+	case <-next.resolved:
+	case <-time.After(time.Second):
+		t.Fatal("Background refresh did not run")
+	}
+
+	cache.mu.Lock()
+	refreshed := cache.entries[linkKey(link)].Value.(*cacheEntry).response.LongLink
+	cache.mu.Unlock()
+	if refreshed != "https://example.page.link/?link=https://new.example.com" {
+		t.Errorf("refreshed entry = %v, want new long link", refreshed)
+	}
+	if stats := cache.Stats(); stats.StaleHits != 1 {
+		t.Errorf("StaleHits = %d, want 1", stats.StaleHits)
+	}
+}
+
+func TestCachingLinkResolverEviction(t *testing.T) {
+	next := &countingResolver{longLink: "https://example.page.link/?link=https://example.com"}
+	cache := NewCachingLinkResolver(next, CacheOptions{Size: 2, TTL: time.Minute})
+
+	for _, code := range []string{"a", "b", "a", "c"} {
+		if _, err := cache.Resolve(context.Background(), mustParseURL(t, "https://example.page.link/"+code)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Size != 2 || stats.Evictions != 1 {
+		t.Errorf("Stats() = %+v, want size 2 and 1 eviction", stats)
+	}
+	if _, ok := cache.entries["example.page.link/b"]; ok {
+		t.Error("Least recently used entry was not evicted")
+	}
+}
+
+func TestCachingLinkResolverInvalidateDuringLookup(t *testing.T) {
+	next := &blockingResolver{release: make(chan struct{})}
+	cache := NewCachingLinkResolver(next, CacheOptions{Size: 10, TTL: time.Minute})
+	link := mustParseURL(t, "https://example.page.link/abc")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Resolve(context.Background(), link)
+	}()
+	for next.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cache.Invalidate(link)
+	close(next.release)
+	<-done
+
+	if size := cache.Stats().Size; size != 0 {
+		t.Errorf("cache size = %d, want the answer from before the invalidation dropped", size)
+	}
+}
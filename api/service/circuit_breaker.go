@@ -0,0 +1,72 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker opens after threshold consecutive failures and rejects calls until
+// cooldown has passed. It then lets a single trial call through (half-open): success
+// closes the circuit, failure opens it for another cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trialBusy bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+func (b *circuitBreaker) Allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.trialBusy {
+		return false
+	}
+	b.trialBusy = true
+	return true
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.trialBusy = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	b.failures++
+	b.trialBusy = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+	b.mu.Unlock()
+}
+
+// Release ends a trial call without recording an outcome, e.g. when the caller gave up.
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	b.trialBusy = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.threshold > 0 && b.failures >= b.threshold
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+const webhookClickEventTimeout = 5 * time.Second
+
+// WriterClickEventSink writes click events as JSON lines, e.g. to stdout.
+type WriterClickEventSink struct {
+	name   string
+	writer io.Writer
+}
+
+func NewWriterClickEventSink(name string, writer io.Writer) *WriterClickEventSink {
+	return &WriterClickEventSink{name: name, writer: writer}
+}
+
+func (s *WriterClickEventSink) Name() string {
+	return s.name
+}
+
+func (s *WriterClickEventSink) Write(ctx context.Context, events []model.ClickEvent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	_, err := s.writer.Write(buf.Bytes())
+	return err
+}
+
+func (s *WriterClickEventSink) Close() error {
+	return nil
+}
+
+// FileClickEventSink appends click events as JSON lines to a file. Once the file would grow past
+// maxBytes it is renamed to path.1, older backups shift up and the oldest beyond maxBackups is
+// removed.
+type FileClickEventSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+// OpenFileClickEventSink opens the file for appending. maxBytes 0 disables rotation.
+func OpenFileClickEventSink(path string, maxBytes int64, maxBackups int) (*FileClickEventSink, error) {
+	s := &FileClickEventSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileClickEventSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open click event file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat click event file: %w", err)
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileClickEventSink) Name() string {
+	return "file"
+}
+
+func (s *FileClickEventSink) Write(ctx context.Context, events []model.ClickEvent) error {
+	writer := bufio.NewWriter(s.file)
+	for i := range events {
+		line, err := json.Marshal(&events[i])
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if err := s.rotate(); err != nil {
+				return err
+			}
+			writer.Reset(s.file)
+		}
+
+		if _, err := writer.Write(line); err != nil {
+			return err
+		}
+		s.size += int64(len(line))
+	}
+	return writer.Flush()
+}
+
+// rotate moves the full file aside and opens a new one. When that fails it reopens the current
+// file, so later writes still land somewhere.
+func (s *FileClickEventSink) rotate() error {
+	if err := s.moveAside(); err != nil {
+		if openErr := s.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileClickEventSink) moveAside() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close click event file: %w", err)
+	}
+
+	if s.maxBackups <= 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove click event file: %w", err)
+		}
+		return nil
+	}
+
+	os.Remove(s.backupPath(s.maxBackups))
+	for n := s.maxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(s.backupPath(n), s.backupPath(n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate click event file: %w", err)
+		}
+	}
+	if err := os.Rename(s.path, s.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate click event file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileClickEventSink) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
+
+func (s *FileClickEventSink) Close() error {
+	return s.file.Close()
+}
+
+// WebhookClickEventSink POSTs each batch of click events as a JSON array.
+type WebhookClickEventSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookClickEventSink creates the sink. token, if set, is sent as a bearer token; client may
+// be nil to use one with a short timeout.
+func NewWebhookClickEventSink(url, token string, client *http.Client) *WebhookClickEventSink {
+	if client == nil {
+		client = &http.Client{Timeout: webhookClickEventTimeout}
+	}
+	return &WebhookClickEventSink{url: url, token: token, client: client}
+}
+
+func (s *WebhookClickEventSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookClickEventSink) Write(ctx context.Context, events []model.ClickEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("click event webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("click event webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *WebhookClickEventSink) Close() error {
+	return nil
+}
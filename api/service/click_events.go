@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultClickEventBuffer        = 10000
+	defaultClickEventBatchSize     = 100
+	defaultClickEventFlushInterval = 5 * time.Second
+	clickEventWriteTimeout         = 10 * time.Second
+	// clickEventAbortGrace is how long Close waits for sinks to give up their current write once
+	// its context is done.
+	clickEventAbortGrace = time.Second
+)
+
+// ClickEventSink receives click events in batches. Each sink is written from a single goroutine,
+// so implementations need not be safe for concurrent use.
+type ClickEventSink interface {
+	Name() string
+	Write(ctx context.Context, events []model.ClickEvent) error
+	Close() error
+}
+
+type ClickEventOptions struct {
+	// Buffer is how many events may wait for each sink. Events arriving at a full buffer are dropped.
+	Buffer int
+	// BatchSize is the most events handed to a sink at once.
+	BatchSize int
+	// FlushInterval is the longest an event waits for its batch to fill.
+	FlushInterval time.Duration
+}
+
+type ClickEventSinkStats struct {
+	Queued  int   `json:"queued"`
+	Emitted int64 `json:"emitted"`
+	Dropped int64 `json:"dropped"`
+	Written int64 `json:"written"`
+	Failed  int64 `json:"failed"`
+}
+
+// ClickEventPipeline fans click events out to its sinks without ever blocking the caller: every
+// sink drains its own buffer, and an event that does not fit is dropped and counted.
+type ClickEventPipeline struct {
+	mu      sync.RWMutex
+	closed  bool
+	workers []*clickEventWorker
+	abort   context.CancelFunc
+}
+
+type clickEventWorker struct {
+	sink          ClickEventSink
+	events        chan model.ClickEvent
+	batchSize     int
+	flushInterval time.Duration
+	// aborted is done when Close gives up on draining; the worker then drops what is left.
+	aborted context.Context
+	done    chan struct{}
+
+	emitted atomic.Int64
+	dropped atomic.Int64
+	written atomic.Int64
+	failed  atomic.Int64
+}
+
+func NewClickEventPipeline(sinks []ClickEventSink, options ClickEventOptions) *ClickEventPipeline {
+	if options.Buffer <= 0 {
+		options.Buffer = defaultClickEventBuffer
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultClickEventBatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = defaultClickEventFlushInterval
+	}
+
+	aborted, abort := context.WithCancel(context.Background())
+	p := &ClickEventPipeline{abort: abort}
+	for _, sink := range sinks {
+		worker := &clickEventWorker{
+			sink:          sink,
+			events:        make(chan model.ClickEvent, options.Buffer),
+			batchSize:     options.BatchSize,
+			flushInterval: options.FlushInterval,
+			aborted:       aborted,
+			done:          make(chan struct{}),
+		}
+		p.workers = append(p.workers, worker)
+		go worker.run()
+	}
+	return p
+}
+
+// NewClickEventPipelineFromConfig builds the sinks named by CLICK_EVENTS_SINKS and adds sinks,
+// such as the link stats, built by the caller. It returns nil when there is no sink at all.
+func NewClickEventPipelineFromConfig(cfg *config.Config, sinks ...ClickEventSink) (*ClickEventPipeline, error) {
+	var options ClickEventOptions
+	var err error
+	if options.Buffer, err = parseOptionalInt(cfg.ClickEventBuffer); err != nil {
+		return nil, fmt.Errorf("invalid click event buffer: %w", err)
+	}
+	if options.BatchSize, err = parseOptionalInt(cfg.ClickEventBatchSize); err != nil {
+		return nil, fmt.Errorf("invalid click event batch size: %w", err)
+	}
+	if options.FlushInterval, err = parseOptionalDuration(cfg.ClickEventFlushInterval); err != nil {
+		return nil, fmt.Errorf("invalid click event flush interval: %w", err)
+	}
+
+	sinks = append([]ClickEventSink(nil), sinks...)
+	closeSinks := func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}
+	for _, name := range strings.Split(cfg.ClickEventSinks, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "":
+			continue
+		case "stdout":
+			sinks = append(sinks, NewWriterClickEventSink("stdout", os.Stdout))
+		case "file":
+			maxBytes, err := parseOptionalInt(cfg.ClickEventFileMaxBytes)
+			if err != nil {
+				closeSinks()
+				return nil, fmt.Errorf("invalid click event file max bytes: %w", err)
+			}
+			maxBackups, err := parseOptionalInt(cfg.ClickEventFileMaxBackups)
+			if err != nil {
+				closeSinks()
+				return nil, fmt.Errorf("invalid click event file max backups: %w", err)
+			}
+			sink, err := OpenFileClickEventSink(cfg.ClickEventFile, int64(maxBytes), maxBackups)
+			if err != nil {
+				closeSinks()
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "webhook":
+			if cfg.ClickEventWebhookURL == "" {
+				closeSinks()
+				return nil, fmt.Errorf("click event webhook sink requires CLICK_EVENTS_WEBHOOK_URL")
+			}
+			sinks = append(sinks, NewWebhookClickEventSink(cfg.ClickEventWebhookURL, cfg.ClickEventWebhookToken, nil))
+		default:
+			closeSinks()
+			return nil, fmt.Errorf("invalid click event sink: %s", name)
+		}
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return NewClickEventPipeline(sinks, options), nil
+}
+
+// Emit queues the event for every sink. It never blocks; sinks whose buffer is full drop it.
+func (p *ClickEventPipeline) Emit(event model.ClickEvent) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+
+	for _, worker := range p.workers {
+		worker.emitted.Add(1)
+		select {
+		case worker.events <- event:
+		default:
+			worker.dropped.Add(1)
+		}
+	}
+}
+
+// Stats returns the counters of each sink by name.
+func (p *ClickEventPipeline) Stats() map[string]ClickEventSinkStats {
+	stats := make(map[string]ClickEventSinkStats, len(p.workers))
+	for _, worker := range p.workers {
+		stats[worker.sink.Name()] = ClickEventSinkStats{
+			Queued:  len(worker.events),
+			Emitted: worker.emitted.Load(),
+			Dropped: worker.dropped.Load(),
+			Written: worker.written.Load(),
+			Failed:  worker.failed.Load(),
+		}
+	}
+	return stats
+}
+
+// Close stops accepting events, waits until the queued ones are written or ctx is done, and
+// closes the sinks. Events still queued when ctx is done are dropped and counted.
+func (p *ClickEventPipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	for _, worker := range p.workers {
+		close(worker.events)
+	}
+	p.mu.Unlock()
+
+	var firstErr error
+	for _, worker := range p.workers {
+		select {
+		case <-worker.done:
+		case <-ctx.Done():
+			if firstErr == nil {
+				firstErr = fmt.Errorf("click events not drained: %w", ctx.Err())
+			}
+			p.abort()
+			select {
+			case <-worker.done:
+			case <-time.After(clickEventAbortGrace):
+				// A sink ignoring its context is still writing and cannot be closed under it.
+				log.Error().Str("sink", worker.sink.Name()).Msg("Click event sink did not stop writing")
+				continue
+			}
+		}
+		if err := worker.sink.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close click event sink %s: %w", worker.sink.Name(), err)
+		}
+	}
+	p.abort()
+	return firstErr
+}
+
+func (w *clickEventWorker) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]model.ClickEvent, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(w.aborted, clickEventWriteTimeout)
+		err := w.sink.Write(ctx, batch)
+		cancel()
+		if err != nil {
+			w.failed.Add(int64(len(batch)))
+			log.Error().Err(err).Str("sink", w.sink.Name()).Int("events", len(batch)).Msg("Failed to write click events")
+		} else {
+			w.written.Add(int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case event, ok := <-w.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-w.aborted.Done():
+			dropped := len(batch)
+			for range w.events {
+				dropped++
+			}
+			w.dropped.Add(int64(dropped))
+			return
+		}
+	}
+}
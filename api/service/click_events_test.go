@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+// blockingClickEventSink holds every write until release is closed.
+type blockingClickEventSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	written int
+}
+
+func (s *blockingClickEventSink) Name() string { return "blocking" }
+
+func (s *blockingClickEventSink) Write(ctx context.Context, events []model.ClickEvent) error {
+	<-s.release
+	s.mu.Lock()
+	s.written += len(events)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *blockingClickEventSink) Close() error { return nil }
+
+type failingClickEventSink struct{}
+
+func (failingClickEventSink) Name() string { return "failing" }
+
+func (failingClickEventSink) Write(ctx context.Context, events []model.ClickEvent) error {
+	return errors.New("sink unavailable")
+}
+
+func (failingClickEventSink) Close() error { return nil }
+
+// stalledClickEventSink holds every write until its context is done.
+type stalledClickEventSink struct {
+	closed bool
+}
+
+func (s *stalledClickEventSink) Name() string { return "stalled" }
+
+func (s *stalledClickEventSink) Write(ctx context.Context, events []model.ClickEvent) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *stalledClickEventSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestClickEventPipelineDropsWhenFull(t *testing.T) {
+	sink := &blockingClickEventSink{release: make(chan struct{})}
+	pipeline := NewClickEventPipeline([]ClickEventSink{sink, failingClickEventSink{}}, ClickEventOptions{Buffer: 2, BatchSize: 1})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			pipeline.Emit(model.ClickEvent{Decision: "link"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Emit blocked on a stalled sink")
+	}
+
+	close(sink.release)
+	if err := pipeline.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pipeline.Emit(model.ClickEvent{Decision: "link"})
+
+	stats := pipeline.Stats()
+	blocking := stats["blocking"]
+	if blocking.Emitted != 10 || blocking.Dropped == 0 || blocking.Written+blocking.Dropped != 10 {
+		t.Errorf("blocking stats = %+v, want drops and every event accounted for", blocking)
+	}
+	if int64(sink.written) != blocking.Written {
+		t.Errorf("sink wrote %d events, stats say %d", sink.written, blocking.Written)
+	}
+	if failing := stats["failing"]; failing.Failed == 0 || failing.Failed+failing.Dropped != 10 || failing.Written != 0 {
+		t.Errorf("failing stats = %+v, want every event failed or dropped", failing)
+	}
+}
+
+func TestClickEventPipelineCloseTimeout(t *testing.T) {
+	stalled := &stalledClickEventSink{}
+	other := &stalledClickEventSink{}
+	pipeline := NewClickEventPipeline([]ClickEventSink{stalled, other}, ClickEventOptions{Buffer: 10, BatchSize: 2})
+	for i := 0; i < 5; i++ {
+		pipeline.Emit(model.ClickEvent{Decision: "link"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pipeline.Close(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Close() error = %v, want context.Canceled", err)
+	}
+	if !stalled.closed || !other.closed {
+		t.Error("Close() left a sink open")
+	}
+	stats := pipeline.Stats()["stalled"]
+	if stats.Emitted != 5 || stats.Written+stats.Dropped+stats.Failed != 5 {
+		t.Errorf("stats = %+v, want every event accounted for", stats)
+	}
+}
+
+func TestClickEventPipelineFlushInterval(t *testing.T) {
+	sink := &blockingClickEventSink{release: make(chan struct{})}
+	close(sink.release)
+	pipeline := NewClickEventPipeline([]ClickEventSink{sink}, ClickEventOptions{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer pipeline.Close(context.Background())
+
+	pipeline.Emit(model.ClickEvent{Decision: "link"})
+	deadline := time.Now().Add(time.Second)
+	for pipeline.Stats()["blocking"].Written != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("partial batch was not flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFileClickEventSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clicks.jsonl")
+	event := model.ClickEvent{Domain: "example.page.link", ShortCode: "abc", Decision: "link"}
+	line, _ := json.Marshal(event)
+	lineSize := int64(len(line) + 1)
+
+	// Two events fit in a file; seven events leave three full files and a partial one.
+	sink, err := OpenFileClickEventSink(path, 2*lineSize, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := sink.Write(context.Background(), []model.ClickEvent{event}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, wantLines := range map[string]int{"clicks.jsonl": 1, "clicks.jsonl.1": 2, "clicks.jsonl.2": 2} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(data), "\n"); got != wantLines {
+			t.Errorf("%s has %d lines, want %d", name, got, wantLines)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup beyond the limit was kept: %v", err)
+	}
+}
+
+func TestFileClickEventSinkRotationFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clicks.jsonl")
+	event := model.ClickEvent{Domain: "example.page.link", ShortCode: "abc", Decision: "link"}
+	line, _ := json.Marshal(event)
+
+	sink, err := OpenFileClickEventSink(path, int64(len(line)+1), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Write(context.Background(), []model.ClickEvent{event}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory that is not empty in place of the backup makes the rename fail.
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), []model.ClickEvent{event}); err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+
+	// Once the backup can be written again, the sink has not lost its file.
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), []model.ClickEvent{event}); err != nil {
+		t.Fatalf("Write() after a failed rotation error = %v", err)
+	}
+	for _, name := range []string{path, path + ".1"} {
+		if data, err := os.ReadFile(name); err != nil || strings.Count(string(data), "\n") != 1 {
+			t.Errorf("%s = %q, %v, want one event", name, data, err)
+		}
+	}
+}
+
+func TestWebhookClickEventSink(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]model.ClickEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var batch []model.ClickEvent
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	pipeline := NewClickEventPipeline([]ClickEventSink{NewWebhookClickEventSink(server.URL, "secret", nil)}, ClickEventOptions{BatchSize: 2})
+	for i := 0; i < 5; i++ {
+		pipeline.Emit(model.ClickEvent{Decision: "link"})
+	}
+	if err := pipeline.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[2]) != 1 {
+		t.Errorf("batches = %v, want sizes 2, 2, 1", batches)
+	}
+	if stats := pipeline.Stats()["webhook"]; stats.Written != 5 {
+		t.Errorf("stats = %+v, want 5 written", stats)
+	}
+
+	unauthorized := NewWebhookClickEventSink(server.URL, "", nil)
+	if err := unauthorized.Write(context.Background(), []model.ClickEvent{{Decision: "link"}}); err == nil {
+		t.Error("expected an error for a rejected batch")
+	}
+}
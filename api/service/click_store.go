@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+var ErrClickNotFound = errors.New("click not found")
+
+// ClickStore keeps recent store redirects for install attribution.
+type ClickStore interface {
+	Record(ctx context.Context, click *model.Click) error
+	// Take returns the click with the token and removes it so it attributes only one install.
+	Take(ctx context.Context, token string) (*model.Click, error)
+	// Since returns the clicks for a platform recorded at or after since.
+	Since(ctx context.Context, platform string, since time.Time) ([]model.Click, error)
+}
+
+// MemoryClickStore holds clicks for the retention period, dropping the oldest once it is full.
+type MemoryClickStore struct {
+	retention time.Duration
+	maxClicks int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	clicks map[string]model.Click
+	// order lists tokens oldest first; taken tokens stay until they reach the front.
+	order []string
+}
+
+func NewMemoryClickStore(retention time.Duration, maxClicks int) *MemoryClickStore {
+	return &MemoryClickStore{
+		retention: retention,
+		maxClicks: maxClicks,
+		now:       time.Now,
+		clicks:    make(map[string]model.Click),
+	}
+}
+
+func (s *MemoryClickStore) Record(ctx context.Context, click *model.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks[click.Token] = *click
+	s.order = append(s.order, click.Token)
+	s.prune()
+	return nil
+}
+
+func (s *MemoryClickStore) Take(ctx context.Context, token string) (*model.Click, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	click, ok := s.clicks[token]
+	if !ok {
+		return nil, ErrClickNotFound
+	}
+	delete(s.clicks, token)
+	return &click, nil
+}
+
+func (s *MemoryClickStore) Since(ctx context.Context, platform string, since time.Time) ([]model.Click, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	var clicks []model.Click
+	for _, click := range s.clicks {
+		if click.Platform == platform && !click.CreatedAt.Before(since) {
+			clicks = append(clicks, click)
+		}
+	}
+	return clicks, nil
+}
+
+func (s *MemoryClickStore) prune() {
+	cutoff := s.now().Add(-s.retention)
+	drop := 0
+	for _, token := range s.order {
+		click, ok := s.clicks[token]
+		if ok && click.CreatedAt.After(cutoff) && len(s.order)-drop <= s.maxClicks {
+			break
+		}
+		delete(s.clicks, token)
+		drop++
+	}
+	// Reslicing is enough: append moves the live tail to a new array once capacity runs out.
+	s.order = s.order[drop:]
+}
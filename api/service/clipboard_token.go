@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+var (
+	ErrInvalidClipboardToken = errors.New("invalid clipboard token")
+	ErrClipboardTokenExpired = errors.New("clipboard token expired")
+)
+
+// clipboardTokenPrefix lets the app recognize a token among whatever else is on the clipboard.
+const clipboardTokenPrefix = "dlr1."
+
+// clipboardTokenSignatureBytes truncates the HMAC-SHA256 to keep the copied text short.
+const clipboardTokenSignatureBytes = 16
+
+const minClipboardTokenSecretLength = 16
+
+type clipboardTokenPayload struct {
+	Link      string `json:"l"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// ClipboardHandoffEnabled reports whether the preview page copies clipboard tokens.
+func (s *InstallAttributionService) ClipboardHandoffEnabled() bool {
+	return len(s.clipboardSecret) > 0
+}
+
+// IssueClipboardToken signs the dynamic link into a token the preview page copies to the
+// clipboard before sending the user to the App Store.
+func (s *InstallAttributionService) IssueClipboardToken(link string) (string, error) {
+	if !s.ClipboardHandoffEnabled() {
+		return "", errors.New("clipboard handoff is disabled")
+	}
+
+	payload, err := json.Marshal(clipboardTokenPayload{Link: link, ExpiresAt: s.now().Add(s.clipboardTTL).Unix()})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal clipboard token: %w", err)
+	}
+	encoded := clipboardTokenPrefix + base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signClipboardToken(encoded), nil
+}
+
+// ExchangeClipboardToken verifies a token copied by the preview page and returns the deep link
+// of the dynamic link it names.
+func (s *InstallAttributionService) ExchangeClipboardToken(ctx context.Context, token string) (*model.InstallAttributionResponse, error) {
+	if !s.ClipboardHandoffEnabled() {
+		return nil, errors.New("clipboard handoff is disabled")
+	}
+
+	token = strings.TrimSpace(token)
+	dot := strings.LastIndexByte(token, '.')
+	if !strings.HasPrefix(token, clipboardTokenPrefix) || dot <= len(clipboardTokenPrefix) {
+		return nil, ErrInvalidClipboardToken
+	}
+	encoded, signature := token[:dot], token[dot+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.signClipboardToken(encoded))) {
+		return nil, ErrInvalidClipboardToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(encoded, clipboardTokenPrefix))
+	if err != nil {
+		return nil, ErrInvalidClipboardToken
+	}
+	var payload clipboardTokenPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrInvalidClipboardToken
+	}
+	if !s.now().Before(time.Unix(payload.ExpiresAt, 0)) {
+		return nil, ErrClipboardTokenExpired
+	}
+
+	link, err := url.Parse(payload.Link)
+	if err != nil {
+		return nil, ErrInvalidClipboardToken
+	}
+	params, status, err := s.linkService.ResolveLink(ctx, link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve clipboard token link: %w", err)
+	}
+	if params == nil || status != LinkActive || params.Get("link") == "" {
+		return &model.InstallAttributionResponse{MatchType: model.MatchNone}, nil
+	}
+
+	return &model.InstallAttributionResponse{
+		DeepLink:      params.Get("link"),
+		RequestedLink: payload.Link,
+		MatchType:     model.MatchUnique,
+		Confidence:    1,
+		UTMSource:     params.Get("utm_source"),
+		UTMMedium:     params.Get("utm_medium"),
+		UTMCampaign:   params.Get("utm_campaign"),
+	}, nil
+}
+
+func (s *InstallAttributionService) signClipboardToken(encoded string) string {
+	mac := hmac.New(sha256.New, s.clipboardSecret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:clipboardTokenSignatureBytes])
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+func TestClipboardToken(t *testing.T) {
+	cfg := &config.Config{
+		AttributionWindow:        "1h",
+		AttributionMinConfidence: "0.6",
+		AttributionMaxClicks:     "100",
+		ClipboardHandoff:         "true",
+		ClipboardTokenSecret:     "0123456789abcdef",
+		ClipboardTokenTTL:        "10m",
+	}
+	linkService := NewDynamicLinkService(cfg, nil, NewMemoryLinkResolver(map[string]string{
+		"example.page.link/abc": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fabc&isi=123&utm_campaign=spring",
+	}))
+	s, err := NewInstallAttributionService(cfg, linkService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	token, err := s.IssueClipboardToken("https://example.page.link/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, clipboardTokenPrefix) {
+		t.Errorf("token %q lacks prefix %q", token, clipboardTokenPrefix)
+	}
+
+	response, err := s.ExchangeClipboardToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.MatchType != model.MatchUnique || response.DeepLink != "https://example.com/abc" || response.UTMCampaign != "spring" {
+		t.Errorf("ExchangeClipboardToken() = %+v, want unique match for the deep link", response)
+	}
+
+	tampered := strings.Replace(token, clipboardTokenPrefix+"e", clipboardTokenPrefix+"f", 1)
+	for name, bad := range map[string]string{
+		"tampered":       tampered,
+		"truncated":      token[:len(token)-2],
+		"missing prefix": strings.TrimPrefix(token, clipboardTokenPrefix),
+		"empty":          "",
+	} {
+		if _, err := s.ExchangeClipboardToken(ctx, bad); !errors.Is(err, ErrInvalidClipboardToken) {
+			t.Errorf("%s: ExchangeClipboardToken() error = %v, want ErrInvalidClipboardToken", name, err)
+		}
+	}
+
+	now = now.Add(10 * time.Minute)
+	if _, err := s.ExchangeClipboardToken(ctx, token); !errors.Is(err, ErrClipboardTokenExpired) {
+		t.Errorf("ExchangeClipboardToken() error = %v, want ErrClipboardTokenExpired", err)
+	}
+}
+
+func TestClipboardTokenRequiresSecret(t *testing.T) {
+	cfg := &config.Config{
+		AttributionWindow:        "1h",
+		AttributionMinConfidence: "0.6",
+		AttributionMaxClicks:     "100",
+		ClipboardHandoff:         "true",
+		ClipboardTokenSecret:     "short",
+		ClipboardTokenTTL:        "10m",
+	}
+	if _, err := NewInstallAttributionService(cfg, nil); err == nil {
+		t.Error("NewInstallAttributionService() accepted a short CLIPBOARD_TOKEN_SECRET")
+	}
+}
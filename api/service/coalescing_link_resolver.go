@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"dynamic-link-redirect/api/model"
+)
+
+type inflightResolve struct {
+	done     chan struct{}
+	response *model.LongLinkResponseModel
+	err      error
+}
+
+// CoalescingLinkResolver keeps at most one outstanding backend lookup per short link.
+// Concurrent callers for the same link wait for and share that lookup's result.
+type CoalescingLinkResolver struct {
+	next LinkResolver
+
+	mu       sync.Mutex
+	inflight map[string]*inflightResolve
+}
+
+func NewCoalescingLinkResolver(next LinkResolver) *CoalescingLinkResolver {
+	return &CoalescingLinkResolver{
+		next:     next,
+		inflight: make(map[string]*inflightResolve),
+	}
+}
+
+func (c *CoalescingLinkResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	key := linkKey(requestedLink)
+
+	c.mu.Lock()
+	call, ok := c.inflight[key]
+	if !ok {
+		call = &inflightResolve{done: make(chan struct{})}
+		c.inflight[key] = call
+		// The shared lookup must not be cancelled when the request that started it goes away,
+		// otherwise every other waiter would see that request's cancellation.
+		go c.resolve(context.WithoutCancel(ctx), key, requestedLink, call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.response, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *CoalescingLinkResolver) resolve(ctx context.Context, key string, requestedLink *url.URL, call *inflightResolve) {
+	call.response, call.err = c.next.Resolve(ctx, requestedLink)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(call.done)
+}
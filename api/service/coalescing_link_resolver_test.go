@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+type blockingResolver struct {
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (r *blockingResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	r.calls.Add(1)
+	<-r.release
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &model.LongLinkResponseModel{LongLink: "https://example.page.link/?link=https://example.com"}, nil
+}
+
+func TestCoalescingLinkResolverSharesResult(t *testing.T) {
+	backendErr := errors.New("backend failed")
+	next := &blockingResolver{release: make(chan struct{}), err: backendErr}
+	resolver := NewCoalescingLinkResolver(next)
+	link := mustParseURL(t, "https://example.page.link/abc")
+
+	const waiters = 20
+	errs := make(chan error, waiters)
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := resolver.Resolve(context.Background(), link)
+			errs <- err
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+	close(errs)
+
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("backend calls = %d, want 1", calls)
+	}
+	for err := range errs {
+		if !errors.Is(err, backendErr) {
+			t.Errorf("Resolve() error = %v, want %v", err, backendErr)
+		}
+	}
+}
+
+func TestCoalescingLinkResolverCancellation(t *testing.T) {
+	next := &blockingResolver{release: make(chan struct{})}
+	resolver := NewCoalescingLinkResolver(next)
+	link := mustParseURL(t, "https://example.page.link/abc")
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error, 1)
+	go func() {
+		_, err := resolver.Resolve(cancelledCtx, link)
+		cancelledErr <- err
+	}()
+
+	result := make(chan *model.LongLinkResponseModel, 1)
+	go func() {
+		response, _ := resolver.Resolve(context.Background(), link)
+		result <- response
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-cancelledErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Resolve() error = %v, want %v", err, context.Canceled)
+	}
+
+	close(next.release)
+	if response := <-result; response == nil {
+		t.Error("Remaining waiter did not receive the shared result")
+	}
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("backend calls = %d, want 1", calls)
+	}
+}
@@ -2,62 +2,168 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
-	"dynamic-link-redirect/api/model"
 	"dynamic-link-redirect/config"
+	"dynamic-link-redirect/tracing"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type DynamicLinkService struct {
-	config *config.Config
+	config   *config.Config
+	tenants  *config.Tenants
+	resolver LinkResolver
+	now      func() time.Time
 }
 
-func NewDynamicLinkService(config *config.Config) *DynamicLinkService {
-	return &DynamicLinkService{config: config}
+// NewDynamicLinkService creates the service. tenants may be nil to serve every host with the
+// environment configuration.
+func NewDynamicLinkService(cfg *config.Config, tenants *config.Tenants, resolver LinkResolver) *DynamicLinkService {
+	if tenants == nil {
+		tenants = config.NewTenants(cfg)
+	}
+	return &DynamicLinkService{config: cfg, tenants: tenants, resolver: resolver, now: time.Now}
+}
+
+// Tenant returns the configuration of the app served on host, which may be a preview host.
+func (s *DynamicLinkService) Tenant(host string) *config.Tenant {
+	if s.tenants == nil {
+		return config.NewTenants(s.config).Default()
+	}
+	return s.tenants.ForHost(host)
+}
+
+// ServesHost reports whether host is a link domain configured in LINK_DOMAINS or TENANTS_FILE.
+func (s *DynamicLinkService) ServesHost(host string) bool {
+	if s.tenants == nil {
+		return config.NewTenants(s.config).Serves(host)
+	}
+	return s.tenants.Serves(host)
 }
 
+type LinkStatus int
+
+const (
+	LinkActive LinkStatus = iota
+	LinkNotYetActive
+	LinkExpired
+)
+
 func (s *DynamicLinkService) GetQueryParamsFromURL(ctx context.Context, url *url.URL) (url.Values, error) {
+	queryParams, status, err := s.ResolveLink(ctx, url)
+	if err != nil || status != LinkActive {
+		return nil, err
+	}
+	return queryParams, nil
+}
+
+// ResolveLink returns the dynamic link parameters for url together with where the current
+// time falls in the link's activation window. Unknown links yield nil parameters.
+func (s *DynamicLinkService) ResolveLink(ctx context.Context, url *url.URL) (url.Values, LinkStatus, error) {
 	log.Debug().Str("url", url.String()).Msg("Getting query params from url")
 
-	reqBody := model.ExchangeShortLinkRequest{
-		RequestedLink: url.String(),
+	ctx, span := tracing.Tracer().Start(ctx, "ResolveLink", trace.WithAttributes(attribute.String("dynamic_link.short_link", url.Host+url.Path)))
+	defer span.End()
+
+	tenant := s.Tenant(url.Host)
+	if IsLongDynamicLink(url) {
+		queryParams := s.longLinkParams(url)
+		if queryParams != nil {
+			tenant.FillStoreIDs(queryParams)
+		}
+		return queryParams, LinkActive, nil
 	}
 
-	jsonBody, err := json.Marshal(reqBody)
+	response, err := s.resolver.Resolve(ctx, url)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to marshal request body")
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "resolve failed")
+		return nil, LinkActive, err
 	}
 
-	resp, err := http.Post(s.config.ExchangeShortLinkEndpoint, "application/json", strings.NewReader(string(jsonBody)))
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to make POST request")
-		return nil, fmt.Errorf("failed to make POST request: %w", err)
+	if response == nil || response.LongLink == "" {
+		return nil, LinkActive, nil
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	queryParams, err := longLinkQueryParams(response.LongLink)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, LinkActive, err
+	}
+	tenant.FillStoreIDs(queryParams)
+
+	now := s.now()
+	switch {
+	case response.NotBefore != nil && now.Before(*response.NotBefore):
+		return queryParams, LinkNotYetActive, nil
+	case response.ExpiresAt != nil && !now.Before(*response.ExpiresAt):
+		return queryParams, LinkExpired, nil
+	}
+	return queryParams, LinkActive, nil
+}
+
+// IsLongDynamicLink reports whether u is itself a long dynamic link, i.e. the domain root
+// with a link query parameter, which needs no exchange.
+func IsLongDynamicLink(u *url.URL) bool {
+	return strings.Trim(u.Path, "/") == "" && u.Query().Get("link") != ""
+}
+
+// longLinkURLParams are the long link parameters holding URLs that users are sent to or shown.
+var longLinkURLParams = []string{"link", "afl", "ifl", "ipfl", "ofl", "si"}
+
+// longLinkParams returns the dynamic link parameters carried by a long link request, or nil if
+// any of its URLs points outside LONG_LINK_ALLOWED_HOSTS. Without allowed hosts long links are
+// not served, since anyone could otherwise redirect through our domains.
+func (s *DynamicLinkService) longLinkParams(u *url.URL) url.Values {
+	queryParams := u.Query()
+	queryParams.Del("from-preview")
+
+	allowedHosts := strings.Fields(strings.ReplaceAll(s.config.LongLinkAllowedHosts, ",", " "))
+	if len(allowedHosts) == 0 {
+		log.Debug().Msg("Long links are disabled without LONG_LINK_ALLOWED_HOSTS")
+		return nil
 	}
 
-	var response model.LongLinkResponseModel
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	for _, key := range longLinkURLParams {
+		value := queryParams.Get(key)
+		if value == "" {
+			continue
+		}
+		target, err := url.Parse(value)
+		if err != nil || !hostAllowed(target.Hostname(), allowedHosts) {
+			log.Warn().Str(key, value).Msg("Long link target not in allowed hosts")
+			return nil
+		}
 	}
+	return queryParams
+}
 
-	if response.LongLink == "" {
-		return nil, nil
+// hostAllowed matches host against patterns such as "example.com" or "*.example.com".
+func hostAllowed(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
 	}
+	return false
+}
 
-	parsedURL, err := url.Parse(response.LongLink)
+// longLinkQueryParams extracts the dynamic link parameters from a long link.
+func longLinkQueryParams(longLink string) (url.Values, error) {
+	parsedURL, err := url.Parse(longLink)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse long link: %w", err)
 	}
@@ -65,8 +171,34 @@ func (s *DynamicLinkService) GetQueryParamsFromURL(ctx context.Context, url *url
 	return parsedURL.Query(), nil
 }
 
+// CacheStats reports link cache counters, or false if caching is disabled.
+func (s *DynamicLinkService) CacheStats() (CacheStats, bool) {
+	cache, ok := s.resolver.(*CachingLinkResolver)
+	if !ok {
+		return CacheStats{}, false
+	}
+	return cache.Stats(), true
+}
+
+// InvalidateLink drops any cached resolution of the short link domain/shortCode, on every host
+// whose links are stored under the same domain.
+func (s *DynamicLinkService) InvalidateLink(domain, shortCode string) {
+	cache, ok := s.resolver.(*CachingLinkResolver)
+	if !ok {
+		return
+	}
+	tenants := s.tenants
+	if tenants == nil {
+		tenants = config.NewTenants(s.config)
+	}
+	for _, host := range tenants.StoreHosts(domain) {
+		cache.Invalidate(&url.URL{Host: host, Path: "/" + shortCode})
+	}
+}
+
 func (s *DynamicLinkService) GetNonPreviewHost(host string) (string, error) {
-	switch s.config.PreviewUrlStyle {
+	previewUrlStyle := s.Tenant(host).PreviewUrlStyle
+	switch previewUrlStyle {
 	case "hyphenated":
 		hostParts := strings.SplitN(host, ".", 2)
 		if len(hostParts) < 2 {
@@ -90,7 +222,7 @@ func (s *DynamicLinkService) GetNonPreviewHost(host string) (string, error) {
 		return host, nil
 
 	default:
-		return "", fmt.Errorf("invalid preview URL style: %s", s.config.PreviewUrlStyle)
+		return "", fmt.Errorf("invalid preview URL style: %s", previewUrlStyle)
 	}
 }
 
@@ -105,7 +237,8 @@ func (s *DynamicLinkService) IsPreviewHost(r *http.Request) (bool, error) {
 
 	log.Debug().Str("host", host).Msg("Checking if host is a preview host")
 
-	switch s.config.PreviewUrlStyle {
+	previewUrlStyle := s.Tenant(host).PreviewUrlStyle
+	switch previewUrlStyle {
 	case "hyphenated":
 		hostParts := strings.Split(host, ".")
 		if len(hostParts) < 2 {
@@ -129,8 +262,8 @@ func (s *DynamicLinkService) IsPreviewHost(r *http.Request) (bool, error) {
 			return true, nil
 		}
 	default:
-		log.Error().Str("style", s.config.PreviewUrlStyle).Msg("Invalid preview URL style configuration")
-		return false, fmt.Errorf("invalid preview URL style: %s", s.config.PreviewUrlStyle)
+		log.Error().Str("style", previewUrlStyle).Msg("Invalid preview URL style configuration")
+		return false, fmt.Errorf("invalid preview URL style: %s", previewUrlStyle)
 	}
 
 	log.Debug().Str("host", host).Msg("Not a preview host")
@@ -149,13 +282,14 @@ func (s *DynamicLinkService) GeneratePreviewURL(originalURL *url.URL) (*url.URL,
 		return nil, fmt.Errorf("invalid host format: %s", previewURL.Host)
 	}
 
-	switch s.config.PreviewUrlStyle {
+	previewUrlStyle := s.Tenant(previewURL.Host).PreviewUrlStyle
+	switch previewUrlStyle {
 	case "hyphenated":
 		hostParts[0] = "preview-" + hostParts[0]
 	case "subdomain":
 		hostParts = append([]string{"preview"}, hostParts...)
 	default:
-		return nil, fmt.Errorf("invalid preview URL style: %s", s.config.PreviewUrlStyle)
+		return nil, fmt.Errorf("invalid preview URL style: %s", previewUrlStyle)
 	}
 
 	previewURL.Host = strings.Join(hostParts, ".")
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/metrics"
+	"dynamic-link-redirect/tracing"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrExchangeUnavailable is returned without calling the backend while the circuit breaker is open.
+var ErrExchangeUnavailable = errors.New("exchange backend unavailable")
+
+// ExchangeError reports a failed exchange call: a network error or an unexpected status code.
+type ExchangeError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *ExchangeError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("exchange backend returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("exchange backend request failed: %v", e.Err)
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExchangeError) retryable() bool {
+	return e.StatusCode == 0 || e.StatusCode >= http.StatusInternalServerError
+}
+
+type ExchangeClientOptions struct {
+	// Timeout bounds each attempt, including reading the response body.
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// BreakerThreshold is the number of consecutive failed calls that opens the circuit; 0 disables it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// ExchangeClient calls the exchangeShortLink backend.
+type ExchangeClient struct {
+	endpoint   string
+	httpClient *http.Client
+	options    ExchangeClientOptions
+	breaker    *circuitBreaker
+}
+
+func NewExchangeClient(endpoint string, options ExchangeClientOptions) *ExchangeClient {
+	return &ExchangeClient{
+		endpoint:   endpoint,
+		httpClient: &http.Client{},
+		options:    options,
+		breaker:    newCircuitBreaker(options.BreakerThreshold, options.BreakerCooldown),
+	}
+}
+
+// ExchangeShortLink returns the long link for requestedLink, or nil if the backend does not know it.
+func (c *ExchangeClient) ExchangeShortLink(ctx context.Context, requestedLink string) (*model.LongLinkResponseModel, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ExchangeShortLink")
+	defer span.End()
+
+	start := time.Now()
+	response, err := c.exchangeShortLink(ctx, requestedLink)
+
+	result := exchangeResult(response, err)
+	metrics.ExchangeRequests.WithLabelValues(result).Inc()
+	metrics.ExchangeDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	span.SetAttributes(attribute.String("exchange.result", result))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, result)
+	}
+	return response, err
+}
+
+// exchangeResult labels the outcome of an exchange call. A caller that went away is not counted
+// as a backend error; a timed out attempt is.
+func exchangeResult(response *model.LongLinkResponseModel, err error) string {
+	var exchangeErr *ExchangeError
+	switch {
+	case err == nil && response == nil:
+		return "not_found"
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrExchangeUnavailable):
+		return "unavailable"
+	case errors.As(err, &exchangeErr):
+		return "error"
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "error"
+}
+
+func (c *ExchangeClient) exchangeShortLink(ctx context.Context, requestedLink string) (*model.LongLinkResponseModel, error) {
+	if !c.breaker.Allow() {
+		return nil, ErrExchangeUnavailable
+	}
+
+	jsonBody, err := json.Marshal(model.ExchangeShortLinkRequest{RequestedLink: requestedLink})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := c.exchange(ctx, jsonBody)
+		if err == nil {
+			c.breaker.Success()
+			return response, nil
+		}
+
+		if ctx.Err() != nil {
+			// The caller went away; that says nothing about the backend's health.
+			c.breaker.Release()
+			return nil, ctx.Err()
+		}
+
+		var exchangeErr *ExchangeError
+		if !errors.As(err, &exchangeErr) || !exchangeErr.retryable() {
+			c.breaker.Success()
+			return nil, err
+		}
+
+		if attempt >= c.options.MaxRetries {
+			c.breaker.Failure()
+			log.Error().Err(err).Int("attempts", attempt+1).Msg("Exchange request failed")
+			return nil, err
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt+1).Msg("Exchange request failed, retrying")
+		if err := sleepContext(ctx, c.backoff(attempt)); err != nil {
+			c.breaker.Release()
+			return nil, err
+		}
+	}
+}
+
+func (c *ExchangeClient) exchange(ctx context.Context, jsonBody []byte) (*model.LongLinkResponseModel, error) {
+	if c.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.Timeout)
+		defer cancel()
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "POST exchangeShortLink", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// The backend continues the trace through the traceparent header.
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	span.SetAttributes(semconv.HTTPRequestMethodKey.String(http.MethodPost), semconv.URLFull(c.endpoint))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "request failed")
+		return nil, &ExchangeError{Err: err}
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ExchangeError{Err: fmt.Errorf("failed to read response body: %w", err)}
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ExchangeError{StatusCode: resp.StatusCode}
+	}
+
+	var response model.LongLinkResponseModel
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &ExchangeError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to unmarshal response: %w", err)}
+	}
+
+	return &response, nil
+}
+
+// backoff returns a full-jitter delay in [0, RetryBackoff*2^attempt).
+func (c *ExchangeClient) backoff(attempt int) time.Duration {
+	maxDelay := c.options.RetryBackoff << attempt
+	if maxDelay <= 0 {
+		return 0
+	}
+	return rand.N(maxDelay)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+func TestExchangeClient(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		maxRetries       int
+		expectedLongLink string
+		expectedCalls    int32
+		expectedStatus   int
+	}{
+		{
+			name:             "success",
+			statuses:         []int{http.StatusOK},
+			expectedLongLink: "https://example.page.link/?link=https://example.com",
+			expectedCalls:    1,
+		},
+		{
+			name:          "not found",
+			statuses:      []int{http.StatusNotFound},
+			expectedCalls: 1,
+		},
+		{
+			name:             "retries server errors",
+			statuses:         []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries:       2,
+			expectedLongLink: "https://example.page.link/?link=https://example.com",
+			expectedCalls:    3,
+		},
+		{
+			name:           "gives up after max retries",
+			statuses:       []int{http.StatusInternalServerError},
+			maxRetries:     2,
+			expectedCalls:  3,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "does not retry client errors",
+			statuses:       []int{http.StatusBadRequest},
+			maxRetries:     2,
+			expectedCalls:  1,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := int(calls.Add(1)) - 1
+				status := tt.statuses[min(call, len(tt.statuses)-1)]
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte(`{"longLink": "https://example.page.link/?link=https://example.com"}`))
+				}
+			}))
+			defer server.Close()
+
+			client := NewExchangeClient(server.URL, ExchangeClientOptions{
+				Timeout:      time.Second,
+				MaxRetries:   tt.maxRetries,
+				RetryBackoff: time.Millisecond,
+			})
+
+			response, err := client.ExchangeShortLink(context.Background(), "https://example.page.link/abc")
+
+			if calls.Load() != tt.expectedCalls {
+				t.Errorf("backend calls = %d, want %d", calls.Load(), tt.expectedCalls)
+			}
+
+			if tt.expectedStatus != 0 {
+				var exchangeErr *ExchangeError
+				if !errors.As(err, &exchangeErr) || exchangeErr.StatusCode != tt.expectedStatus {
+					t.Errorf("ExchangeShortLink() error = %v, want status %d", err, tt.expectedStatus)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.expectedLongLink == "" {
+				if response != nil {
+					t.Errorf("ExchangeShortLink() = %v, want nil", response)
+				}
+				return
+			}
+			if response == nil || response.LongLink != tt.expectedLongLink {
+				t.Errorf("ExchangeShortLink() = %v, want %v", response, tt.expectedLongLink)
+			}
+		})
+	}
+}
+
+func TestExchangeClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	client := NewExchangeClient(server.URL, ExchangeClientOptions{Timeout: 20 * time.Millisecond})
+
+	_, err := client.ExchangeShortLink(context.Background(), "https://example.page.link/abc")
+	var exchangeErr *ExchangeError
+	if !errors.As(err, &exchangeErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ExchangeShortLink() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestExchangeClientCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"longLink": "https://example.page.link/?link=https://example.com"}`))
+	}))
+	defer server.Close()
+
+	client := NewExchangeClient(server.URL, ExchangeClientOptions{
+		Timeout:          time.Second,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	})
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ExchangeShortLink(context.Background(), "https://example.page.link/abc"); err == nil {
+			t.Fatal("Expected an error but got none")
+		}
+	}
+
+	if _, err := client.ExchangeShortLink(context.Background(), "https://example.page.link/abc"); !errors.Is(err, ErrExchangeUnavailable) {
+		t.Errorf("ExchangeShortLink() error = %v, want %v", err, ErrExchangeUnavailable)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("backend calls = %d, want 2", calls.Load())
+	}
+
+	healthy.Store(true)
+	now = now.Add(2 * time.Minute)
+
+	if _, err := client.ExchangeShortLink(context.Background(), "https://example.page.link/abc"); err != nil {
+		t.Errorf("Unexpected error after cooldown: %v", err)
+	}
+	if client.breaker.Open() {
+		t.Error("Circuit breaker still open after successful trial call")
+	}
+}
+
+func TestExchangeResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *model.LongLinkResponseModel
+		err      error
+		want     string
+	}{
+		{"found", &model.LongLinkResponseModel{LongLink: "https://example.com"}, nil, "ok"},
+		{"unknown link", nil, nil, "not_found"},
+		{"breaker open", nil, ErrExchangeUnavailable, "unavailable"},
+		{"backend status", nil, &ExchangeError{StatusCode: http.StatusBadGateway}, "error"},
+		{"attempt timeout", nil, &ExchangeError{Err: context.DeadlineExceeded}, "error"},
+		{"caller gone", nil, context.Canceled, "canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exchangeResult(tt.response, tt.err); got != tt.want {
+				t.Errorf("exchangeResult() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"context"
+	"net/url"
+
+	"dynamic-link-redirect/api/model"
+)
+
+// ExchangeLinkResolver resolves links by calling the exchangeShortLink backend.
+type ExchangeLinkResolver struct {
+	client *ExchangeClient
+}
+
+func NewExchangeLinkResolver(client *ExchangeClient) *ExchangeLinkResolver {
+	return &ExchangeLinkResolver{client: client}
+}
+
+func (r *ExchangeLinkResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	return r.client.ExchangeShortLink(ctx, requestedLink.String())
+}
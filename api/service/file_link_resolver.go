@@ -0,0 +1,29 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// NewFileLinkResolver loads a JSON object mapping short links to long links, e.g.
+//
+//	{"https://example.page.link/abc": "https://example.page.link/?link=https://example.com&apn=com.example"}
+//
+// It lets the redirector serve a snapshot of links while the exchange backend is unavailable.
+func NewFileLinkResolver(path string) (*MemoryLinkResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read link file: %w", err)
+	}
+
+	var links map[string]string
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal link file: %w", err)
+	}
+
+	log.Info().Str("path", path).Int("links", len(links)).Msg("Loaded links from file")
+	return NewMemoryLinkResolver(links), nil
+}
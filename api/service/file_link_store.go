@@ -0,0 +1,296 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"dynamic-link-redirect/api/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	logOpPut    = "put"
+	logOpDelete = "delete"
+)
+
+type linkLogRecord struct {
+	Op        string             `json:"op"`
+	Link      *model.DynamicLink `json:"link,omitempty"`
+	Domain    string             `json:"domain,omitempty"`
+	ShortCode string             `json:"shortCode,omitempty"`
+}
+
+// FileLinkStore persists links in an append-only JSON lines log and serves reads from memory.
+// The log is replayed on open and compacted when it holds mostly superseded records.
+type FileLinkStore struct {
+	path  string
+	links *MemoryLinkStore
+
+	mu   sync.Mutex
+	file *os.File
+	// size is the end of the last complete record, where a failed append is truncated back to.
+	size int64
+}
+
+func OpenFileLinkStore(path string) (*FileLinkStore, error) {
+	s := &FileLinkStore{path: path, links: NewMemoryLinkStore()}
+
+	records, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	live := len(s.links.links)
+	if records > 2*live+100 {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open link store: %w", err)
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		s.file.Close()
+		return nil, fmt.Errorf("failed to stat link store: %w", err)
+	}
+	s.size = info.Size()
+
+	log.Info().Str("path", path).Int("links", live).Int("records", records).Msg("Opened link store")
+	return s, nil
+}
+
+// replay loads the log into memory. A torn final line from an interrupted write is truncated
+// away; a corrupt line in the middle of the log is skipped so the records after it still load.
+func (s *FileLinkStore) replay() (int, error) {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open link store: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	records := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				log.Warn().Str("path", s.path).Int64("offset", offset).Msg("Truncating incomplete link store record")
+				if err := file.Truncate(offset); err != nil {
+					return 0, fmt.Errorf("failed to truncate link store: %w", err)
+				}
+			}
+			return records, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read link store: %w", err)
+		}
+
+		var record linkLogRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			log.Warn().Err(err).Str("path", s.path).Int64("offset", offset).Msg("Skipping corrupt link store record")
+		} else {
+			s.apply(record)
+		}
+		offset += int64(len(line))
+		records++
+	}
+}
+
+func (s *FileLinkStore) apply(record linkLogRecord) {
+	switch record.Op {
+	case logOpPut:
+		if record.Link != nil {
+			s.links.links[storeKey(record.Link.Domain, record.Link.ShortCode)] = *record.Link
+		}
+	case logOpDelete:
+		delete(s.links.links, storeKey(record.Domain, record.ShortCode))
+	default:
+		log.Warn().Str("op", record.Op).Msg("Skipping unknown link store record")
+	}
+}
+
+func (s *FileLinkStore) compact() error {
+	tmpPath := s.path + ".compact"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted link store: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, link := range s.links.links {
+		if err := encoder.Encode(linkLogRecord{Op: logOpPut, Link: &link}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write compacted link store: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write compacted link store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync compacted link store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close compacted link store: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace link store: %w", err)
+	}
+	// The rename itself is only durable once the directory is synced.
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("failed to sync link store directory: %w", err)
+	}
+	log.Info().Str("path", s.path).Int("links", len(s.links.links)).Msg("Compacted link store")
+	return nil
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (s *FileLinkStore) append(record linkLogRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal link store record: %w", err)
+	}
+	if err := s.write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append link store record: %w", err)
+	}
+	return nil
+}
+
+// write appends complete records and syncs them. On failure the log is truncated back to its
+// last complete record, so a partial write never ends up in the middle of the log once later
+// appends succeed.
+func (s *FileLinkStore) write(data []byte) error {
+	_, err := s.file.Write(data)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		if truncateErr := s.file.Truncate(s.size); truncateErr != nil {
+			log.Error().Err(truncateErr).Str("path", s.path).Msg("Failed to truncate link store after a failed write")
+		}
+		return err
+	}
+	s.size += int64(len(data))
+	return nil
+}
+
+func (s *FileLinkStore) Get(ctx context.Context, domain, shortCode string) (*model.DynamicLink, error) {
+	return s.links.Get(ctx, domain, shortCode)
+}
+
+func (s *FileLinkStore) Create(ctx context.Context, link *model.DynamicLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.links.Get(ctx, link.Domain, link.ShortCode); err == nil {
+		return ErrLinkExists
+	}
+	if err := s.append(linkLogRecord{Op: logOpPut, Link: link}); err != nil {
+		return err
+	}
+	return s.links.Create(ctx, link)
+}
+
+// CreateBatch stores many links with a single sync of the log.
+func (s *FileLinkStore) CreateBatch(ctx context.Context, links []*model.DynamicLink) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]error, len(links))
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	pending := map[string]bool{}
+	for n, link := range links {
+		if link == nil {
+			continue
+		}
+		key := storeKey(link.Domain, link.ShortCode)
+		if _, err := s.links.Get(ctx, link.Domain, link.ShortCode); err == nil || pending[key] {
+			errs[n] = ErrLinkExists
+			continue
+		}
+		if err := encoder.Encode(linkLogRecord{Op: logOpPut, Link: link}); err != nil {
+			errs[n] = fmt.Errorf("failed to marshal link store record: %w", err)
+			continue
+		}
+		pending[key] = true
+	}
+
+	if buf.Len() > 0 {
+		if err := s.write(buf.Bytes()); err != nil {
+			for n, link := range links {
+				if link != nil && errs[n] == nil {
+					errs[n] = fmt.Errorf("failed to append link store records: %w", err)
+				}
+			}
+			return errs
+		}
+	}
+
+	for n, link := range links {
+		if link != nil && errs[n] == nil {
+			errs[n] = s.links.Create(ctx, link)
+		}
+	}
+	return errs
+}
+
+func (s *FileLinkStore) Update(ctx context.Context, link *model.DynamicLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.links.Get(ctx, link.Domain, link.ShortCode); err != nil {
+		return err
+	}
+	if err := s.append(linkLogRecord{Op: logOpPut, Link: link}); err != nil {
+		return err
+	}
+	return s.links.Update(ctx, link)
+}
+
+func (s *FileLinkStore) Delete(ctx context.Context, domain, shortCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.links.Get(ctx, domain, shortCode); err != nil {
+		return err
+	}
+	if err := s.append(linkLogRecord{Op: logOpDelete, Domain: domain, ShortCode: shortCode}); err != nil {
+		return err
+	}
+	return s.links.Delete(ctx, domain, shortCode)
+}
+
+func (s *FileLinkStore) List(ctx context.Context, filter model.LinkFilter) ([]model.DynamicLink, string, error) {
+	return s.links.List(ctx, filter)
+}
+
+func (s *FileLinkStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
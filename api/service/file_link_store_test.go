@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+func TestFileLinkStorePersistsLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.log")
+	ctx := context.Background()
+
+	store, err := OpenFileLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	link := &model.DynamicLink{
+		Domain:    "example.page.link",
+		ShortCode: "abc",
+		LongLink:  "https://example.page.link/?link=https://example.com",
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	if err := store.Create(ctx, link); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+	if err := store.Create(ctx, link); !errors.Is(err, ErrLinkExists) {
+		t.Errorf("Create() duplicate error = %v, want %v", err, ErrLinkExists)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// Simulate a write torn by a crash.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	file.WriteString(`{"op":"put","link":{"domain":"example.page.link"`)
+	file.Close()
+
+	reopened, err := OpenFileLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	got, err := reopened.Get(ctx, "EXAMPLE.page.link", "abc")
+	if err != nil {
+		t.Fatalf("Failed to get link: %v", err)
+	}
+	if got.LongLink != link.LongLink || !got.CreatedAt.Equal(createdAt) {
+		t.Errorf("Get() = %+v, want %+v", got, link)
+	}
+
+	if _, err := reopened.Get(ctx, "example.page.link", "missing"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("Get() missing error = %v, want %v", err, ErrLinkNotFound)
+	}
+
+	second := &model.DynamicLink{Domain: "example.page.link", ShortCode: "def", LongLink: "https://example.page.link/?link=https://example.org"}
+	if err := reopened.Create(ctx, second); err != nil {
+		t.Errorf("Create() after truncation error = %v", err)
+	}
+}
+
+func TestFileLinkStoreReplaysUpdatesAndDeletes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.log")
+	ctx := context.Background()
+
+	store, err := OpenFileLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	for _, code := range []string{"abc", "def"} {
+		link := &model.DynamicLink{Domain: "example.page.link", ShortCode: code, LongLink: "https://example.page.link/?link=https://example.com"}
+		if err := store.Create(ctx, link); err != nil {
+			t.Fatalf("Failed to create link: %v", err)
+		}
+	}
+	updated := &model.DynamicLink{Domain: "example.page.link", ShortCode: "def", LongLink: "https://example.page.link/?link=https://example.org"}
+	if err := store.Update(ctx, updated); err != nil {
+		t.Fatalf("Failed to update link: %v", err)
+	}
+	if err := store.Delete(ctx, "example.page.link", "abc"); err != nil {
+		t.Fatalf("Failed to delete link: %v", err)
+	}
+	if err := store.Update(ctx, &model.DynamicLink{Domain: "example.page.link", ShortCode: "missing"}); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("Update() missing error = %v, want %v", err, ErrLinkNotFound)
+	}
+	store.Close()
+
+	reopened, err := OpenFileLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.Get(ctx, "example.page.link", "abc"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("Get() deleted link error = %v, want %v", err, ErrLinkNotFound)
+	}
+	got, err := reopened.Get(ctx, "example.page.link", "def")
+	if err != nil {
+		t.Fatalf("Failed to get link: %v", err)
+	}
+	if got.LongLink != updated.LongLink {
+		t.Errorf("LongLink = %v, want %v", got.LongLink, updated.LongLink)
+	}
+}
+
+func TestFileLinkStoreSkipsCorruptRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.log")
+	ctx := context.Background()
+
+	content := `{"op":"put","link":{"domain":"example.page.link","shortCode":"abc","longLink":"https://example.page.link/?link=https://example.com"}}
+{"op":"put","link":{"domain":"example.page.li{"op":"put","link":{"domain":"example.page.link","shortCode":"lost"}}
+{"op":"put","link":{"domain":"example.page.link","shortCode":"def","longLink":"https://example.page.link/?link=https://example.org"}}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenFileLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	for _, code := range []string{"abc", "def"} {
+		if _, err := store.Get(ctx, "example.page.link", code); err != nil {
+			t.Errorf("Get(%q) error = %v", code, err)
+		}
+	}
+	if err := store.Create(ctx, &model.DynamicLink{Domain: "example.page.link", ShortCode: "ghi"}); err != nil {
+		t.Errorf("Create() after a corrupt record error = %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+const (
+	healthCheckTimeout = 2 * time.Second
+	// healthCheckCacheTTL lets several load balancers probe at once without each probe reaching
+	// the exchange backend.
+	healthCheckCacheTTL = time.Second
+	// healthProbeLink is exchanged to test the backend; any answer short of a server error will do.
+	healthProbeLink = "https://health.invalid/-/probe"
+)
+
+// healthTemplates are the templates the redirect flow renders, relative to templates/.
+var healthTemplates = []string{"default.html", "detect.html", "escape.html", "gone.html", "preview.html", "social.html"}
+
+// HealthCheck tests one dependency of the service. Check returns nil when it is usable. An
+// optional check is reported but does not fail readiness, for dependencies the redirect flow can
+// do without for a while.
+type HealthCheck struct {
+	Name     string
+	Check    func(ctx context.Context) error
+	Optional bool
+}
+
+// HealthService answers readiness probes. It runs its checks at most once per
+// healthCheckCacheTTL and reports failing as soon as Drain is called.
+type HealthService struct {
+	checks  []HealthCheck
+	started time.Time
+	now     func() time.Time
+
+	draining atomic.Bool
+
+	mu        sync.Mutex
+	results   []model.HealthCheckResult
+	failing   bool
+	checkedAt time.Time
+}
+
+func NewHealthService(checks ...HealthCheck) *HealthService {
+	return &HealthService{checks: checks, started: time.Now(), now: time.Now}
+}
+
+// NewHealthServiceFromConfig checks what serving a redirect depends on: the exchange backends of
+// the tenants or the link store, whichever the link resolver uses, the templates and, with SSL
+// enabled, the certificate. tenants may be nil.
+func NewHealthServiceFromConfig(cfg *config.Config, tenants *config.Tenants, store LinkStore) *HealthService {
+	if tenants == nil {
+		tenants = config.NewTenants(cfg)
+	}
+
+	var checks []HealthCheck
+	switch cfg.LinkResolver {
+	case "exchange":
+		var endpoints []string
+		seen := map[string]bool{}
+		for _, tenant := range tenants.All() {
+			if !seen[tenant.ExchangeShortLinkEndpoint] {
+				seen[tenant.ExchangeShortLinkEndpoint] = true
+				endpoints = append(endpoints, tenant.ExchangeShortLinkEndpoint)
+			}
+		}
+		for _, endpoint := range endpoints {
+			// Cached links keep redirecting and the circuit breaker fails the rest fast, so a
+			// backend outage should not take every replica out of the load balancer at once.
+			check := ExchangeHealthCheck(endpoint, nil)
+			check.Optional = true
+			if len(endpoints) > 1 {
+				check.Name += " " + endpoint
+			}
+			checks = append(checks, check)
+		}
+	case "store":
+		checks = append(checks, LinkStoreHealthCheck(store))
+	}
+	checks = append(checks, TemplatesHealthCheck("templates"))
+	if cfg.SSLEnabled == "true" {
+		checks = append(checks, CertificateHealthCheck(cfg.SSLCertPath, cfg.SSLKeyPath))
+	}
+	return NewHealthService(checks...)
+}
+
+// Drain marks the server as shutting down so load balancers stop sending it requests.
+func (s *HealthService) Drain() {
+	s.draining.Store(true)
+}
+
+// Status runs the checks, or reuses results younger than healthCheckCacheTTL, and reports them.
+// The checks do not run under the context of the probe that triggered them, since their results
+// are shared with every other probe.
+func (s *HealthService) Status() *model.HealthStatus {
+	s.mu.Lock()
+	if s.results == nil || s.now().Sub(s.checkedAt) >= healthCheckCacheTTL {
+		s.results, s.failing = s.runChecks(context.Background())
+		s.checkedAt = s.now()
+	}
+	status := &model.HealthStatus{
+		Status:    model.HealthStatusOK,
+		Draining:  s.draining.Load(),
+		StartedAt: s.started.UTC(),
+		Uptime:    s.now().Sub(s.started).Truncate(time.Second).String(),
+		CheckedAt: s.checkedAt.UTC(),
+		Checks:    append([]model.HealthCheckResult(nil), s.results...),
+	}
+	failing := s.failing
+	s.mu.Unlock()
+
+	switch {
+	case status.Draining:
+		status.Status = model.HealthStatusDraining
+	case failing:
+		status.Status = model.HealthStatusFailing
+	}
+	return status
+}
+
+// runChecks runs every check concurrently, each bounded by healthCheckTimeout.
+func (s *HealthService) runChecks(ctx context.Context) ([]model.HealthCheckResult, bool) {
+	results := make([]model.HealthCheckResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			results[i] = model.HealthCheckResult{
+				Name:     check.Name,
+				Status:   model.HealthStatusOK,
+				Optional: check.Optional,
+				Duration: time.Since(start).Round(time.Microsecond).String(),
+			}
+			if err != nil {
+				results[i].Status = model.HealthStatusFailing
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	failing := false
+	for _, result := range results {
+		if result.Status != model.HealthStatusOK && !result.Optional {
+			failing = true
+		}
+	}
+	return results, failing
+}
+
+// ExchangeHealthCheck exchanges a link no one created. The backend is reachable when it answers
+// with anything but a server error. client may be nil to use the default client.
+func ExchangeHealthCheck(endpoint string, client *http.Client) HealthCheck {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return HealthCheck{Name: "exchange", Check: func(ctx context.Context) error {
+		body, err := json.Marshal(model.ExchangeShortLinkRequest{RequestedLink: healthProbeLink})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("exchange backend unreachable: %w", err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("exchange backend returned status %d", resp.StatusCode)
+		}
+		return nil
+	}}
+}
+
+// LinkStoreHealthCheck looks up a link no one created; only ErrLinkNotFound or a link is healthy.
+func LinkStoreHealthCheck(store LinkStore) HealthCheck {
+	return HealthCheck{Name: "linkStore", Check: func(ctx context.Context) error {
+		if _, err := store.Get(ctx, "health.invalid", "-"); err != nil && !errors.Is(err, ErrLinkNotFound) {
+			return fmt.Errorf("link store unreachable: %w", err)
+		}
+		return nil
+	}}
+}
+
+// TemplatesHealthCheck parses the templates the redirect flow renders from dir.
+func TemplatesHealthCheck(dir string) HealthCheck {
+	return HealthCheck{Name: "templates", Check: func(ctx context.Context) error {
+		for _, name := range healthTemplates {
+			if _, err := template.ParseFiles(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+// CertificateHealthCheck loads the TLS key pair and fails when the certificate is not yet or no
+// longer valid.
+func CertificateHealthCheck(certPath, keyPath string) HealthCheck {
+	return HealthCheck{Name: "certificate", Check: func(ctx context.Context) error {
+		pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		now := time.Now()
+		if now.Before(cert.NotBefore) {
+			return fmt.Errorf("certificate is not valid before %s", cert.NotBefore.UTC().Format(time.RFC3339))
+		}
+		if now.After(cert.NotAfter) {
+			return fmt.Errorf("certificate expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+func TestHealthService(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	var checkErr error
+	runs := 0
+	health := NewHealthService(HealthCheck{Name: "backend", Check: func(ctx context.Context) error {
+		runs++
+		return checkErr
+	}})
+	health.now = func() time.Time { return now }
+
+	if status := health.Status(); status.Status != model.HealthStatusOK {
+		t.Fatalf("status = %q, want %q", status.Status, model.HealthStatusOK)
+	}
+
+	// A failure within the cache TTL is only seen once the cached result expires.
+	checkErr = errors.New("connection refused")
+	if status := health.Status(); status.Status != model.HealthStatusOK || runs != 1 {
+		t.Fatalf("status = %q after %d runs, want cached %q", status.Status, runs, model.HealthStatusOK)
+	}
+	now = now.Add(healthCheckCacheTTL)
+	status := health.Status()
+	if status.Status != model.HealthStatusFailing {
+		t.Fatalf("status = %q, want %q", status.Status, model.HealthStatusFailing)
+	}
+	if len(status.Checks) != 1 || status.Checks[0].Name != "backend" || status.Checks[0].Error != "connection refused" {
+		t.Errorf("checks = %+v", status.Checks)
+	}
+
+	checkErr = nil
+	health.Drain()
+	if status := health.Status(); status.Status != model.HealthStatusDraining || !status.Draining {
+		t.Errorf("status = %q, draining = %v, want %q", status.Status, status.Draining, model.HealthStatusDraining)
+	}
+}
+
+func TestHealthServiceOptionalCheck(t *testing.T) {
+	health := NewHealthService(
+		HealthCheck{Name: "exchange", Check: func(ctx context.Context) error { return errors.New("connection refused") }, Optional: true},
+		HealthCheck{Name: "templates", Check: func(ctx context.Context) error { return nil }},
+	)
+	status := health.Status()
+	if status.Status != model.HealthStatusOK {
+		t.Errorf("status = %q, want %q with only an optional check failing", status.Status, model.HealthStatusOK)
+	}
+	if len(status.Checks) != 2 || status.Checks[0].Status != model.HealthStatusFailing || !status.Checks[0].Optional {
+		t.Errorf("checks = %+v, want the optional failure reported", status.Checks)
+	}
+}
+
+func TestExchangeHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "unknown link", status: http.StatusNotFound},
+		{name: "found link", status: http.StatusOK},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := ExchangeHealthCheck(server.URL, server.Client()).Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		if err := ExchangeHealthCheck(server.URL, nil).Check(context.Background()); err == nil {
+			t.Error("expected an error for a closed server")
+		}
+	})
+}
+
+func TestTemplatesHealthCheck(t *testing.T) {
+	if err := TemplatesHealthCheck("../../templates").Check(context.Background()); err != nil {
+		t.Errorf("templates: %v", err)
+	}
+	if err := TemplatesHealthCheck(t.TempDir()).Check(context.Background()); err == nil {
+		t.Error("expected an error for a directory without templates")
+	}
+}
+
+func TestCertificateHealthCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		wantErr   bool
+	}{
+		{name: "valid", notBefore: time.Now().Add(-time.Hour), notAfter: time.Now().Add(time.Hour)},
+		{name: "expired", notBefore: time.Now().Add(-2 * time.Hour), notAfter: time.Now().Add(-time.Hour), wantErr: true},
+		{name: "not yet valid", notBefore: time.Now().Add(time.Hour), notAfter: time.Now().Add(2 * time.Hour), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certPath, keyPath := writeTestCertificate(t, tt.notBefore, tt.notAfter)
+			err := CertificateHealthCheck(certPath, keyPath).Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := CertificateHealthCheck(filepath.Join(t.TempDir(), "missing.crt"), "missing.key").Check(context.Background()); err == nil {
+		t.Error("expected an error for a missing certificate")
+	}
+}
+
+func writeTestCertificate(t *testing.T, notBefore, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.page.link"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
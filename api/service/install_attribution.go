@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+const clickTokenLength = 22
+
+// ErrInvalidAttributionRequest wraps validation failures of an install attribution request.
+var ErrInvalidAttributionRequest = errors.New("invalid install attribution request")
+
+// Weights of the signals compared by probabilistic matching. They add up to 1. At the default
+// minimum confidence a match needs the IP address plus the OS version or screen.
+const (
+	matchWeightIP        = 0.5
+	matchWeightOSVersion = 0.2
+	matchWeightScreen    = 0.2
+	matchWeightRecency   = 0.1
+)
+
+// ambiguousMatchMargin is how close the runner-up may score before a probabilistic match for a
+// different deep link is declined as ambiguous.
+const ambiguousMatchMargin = 0.05
+
+// InstallAttributionService records store redirects and hands their deep link to the app after
+// install: exactly through the Play Install Referrer on Android, probabilistically otherwise.
+type InstallAttributionService struct {
+	clicks        ClickStore
+	linkService   *DynamicLinkService
+	window        time.Duration
+	minConfidence float64
+	now           func() time.Time
+
+	// clipboardSecret signs clipboard tokens; it is empty when clipboard handoff is disabled.
+	clipboardSecret []byte
+	clipboardTTL    time.Duration
+}
+
+func NewInstallAttributionService(cfg *config.Config, linkService *DynamicLinkService) (*InstallAttributionService, error) {
+	window, err := time.ParseDuration(cfg.AttributionWindow)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("invalid INSTALL_ATTRIBUTION_WINDOW %q", cfg.AttributionWindow)
+	}
+	minConfidence, err := strconv.ParseFloat(cfg.AttributionMinConfidence, 64)
+	if err != nil || minConfidence < 0 || minConfidence > 1 {
+		return nil, fmt.Errorf("invalid INSTALL_ATTRIBUTION_MIN_CONFIDENCE %q", cfg.AttributionMinConfidence)
+	}
+	maxClicks, err := strconv.Atoi(cfg.AttributionMaxClicks)
+	if err != nil || maxClicks <= 0 {
+		return nil, fmt.Errorf("invalid INSTALL_ATTRIBUTION_MAX_CLICKS %q", cfg.AttributionMaxClicks)
+	}
+
+	s := &InstallAttributionService{
+		clicks:        NewMemoryClickStore(window, maxClicks),
+		linkService:   linkService,
+		window:        window,
+		minConfidence: minConfidence,
+		now:           time.Now,
+	}
+
+	if cfg.ClipboardHandoff == "true" {
+		if len(cfg.ClipboardTokenSecret) < minClipboardTokenSecretLength {
+			return nil, fmt.Errorf("CLIPBOARD_TOKEN_SECRET must be at least %d characters", minClipboardTokenSecretLength)
+		}
+		s.clipboardTTL, err = time.ParseDuration(cfg.ClipboardTokenTTL)
+		if err != nil || s.clipboardTTL <= 0 {
+			return nil, fmt.Errorf("invalid CLIPBOARD_TOKEN_TTL %q", cfg.ClipboardTokenTTL)
+		}
+		s.clipboardSecret = []byte(cfg.ClipboardTokenSecret)
+	}
+	return s, nil
+}
+
+// RecordClick stores a store redirect for the dynamic link and returns its match token.
+func (s *InstallAttributionService) RecordClick(ctx context.Context, click *model.Click) (string, error) {
+	token, err := generateShortCode(clickTokenLength)
+	if err != nil {
+		return "", err
+	}
+	click.Token = token
+	click.CreatedAt = s.now().UTC()
+
+	if err := s.clicks.Record(ctx, click); err != nil {
+		return "", fmt.Errorf("failed to record click: %w", err)
+	}
+	return token, nil
+}
+
+// Attribute finds the deep link that led to the install reported by req. ip is the app's
+// address. A response with MatchNone is returned when nothing matches.
+func (s *InstallAttributionService) Attribute(ctx context.Context, req *model.InstallAttributionRequest, ip string) (*model.InstallAttributionResponse, error) {
+	switch req.Platform {
+	case "android", "ios":
+	default:
+		return nil, fmt.Errorf("%w: platform must be android or ios", ErrInvalidAttributionRequest)
+	}
+
+	if req.InstallReferrer != "" {
+		response, err := s.attributeReferrer(ctx, req.InstallReferrer)
+		if err != nil || response != nil {
+			return response, err
+		}
+	}
+
+	return s.attributeProbabilistic(ctx, req, ip)
+}
+
+// Reopen resolves the short link an installed app was opened through, so the app gets the same
+// deep link it would have after install. A response with MatchNone is returned for unknown links.
+func (s *InstallAttributionService) Reopen(ctx context.Context, req *model.ReopenAttributionRequest) (*model.InstallAttributionResponse, error) {
+	switch req.Platform {
+	case "android", "ios":
+	default:
+		return nil, fmt.Errorf("%w: platform must be android or ios", ErrInvalidAttributionRequest)
+	}
+	requestedLink, err := url.Parse(req.RequestedLink)
+	if err != nil || !requestedLink.IsAbs() {
+		return nil, fmt.Errorf("%w: requestedLink must be an absolute URL", ErrInvalidAttributionRequest)
+	}
+
+	params, status, err := s.linkService.ResolveLink(ctx, requestedLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reopened link: %w", err)
+	}
+	if params == nil || status != LinkActive || params.Get("link") == "" {
+		return &model.InstallAttributionResponse{MatchType: model.MatchNone}, nil
+	}
+	return &model.InstallAttributionResponse{
+		DeepLink:      params.Get("link"),
+		RequestedLink: req.RequestedLink,
+		MatchType:     model.MatchUnique,
+		Confidence:    1,
+		UTMSource:     params.Get("utm_source"),
+		UTMMedium:     params.Get("utm_medium"),
+		UTMCampaign:   params.Get("utm_campaign"),
+	}, nil
+}
+
+// attributeReferrer matches the Play Install Referrer set by the Play Store redirect: the
+// click token if it is still known, otherwise the short link itself.
+func (s *InstallAttributionService) attributeReferrer(ctx context.Context, referrer string) (*model.InstallAttributionResponse, error) {
+	values, err := url.ParseQuery(referrer)
+	if err != nil {
+		log.Debug().Err(err).Str("referrer", referrer).Msg("Ignoring unparsable install referrer")
+		return nil, nil
+	}
+
+	if token := values.Get("click_id"); token != "" {
+		click, err := s.clicks.Take(ctx, token)
+		if err == nil {
+			return clickResponse(click, model.MatchUnique, 1), nil
+		}
+		if !errors.Is(err, ErrClickNotFound) {
+			return nil, err
+		}
+	}
+
+	shortLink, err := url.Parse(values.Get("tracking_id"))
+	if err != nil || !shortLink.IsAbs() {
+		return nil, nil
+	}
+	params, _, err := s.linkService.ResolveLink(ctx, shortLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve referrer link: %w", err)
+	}
+	if params == nil || params.Get("link") == "" {
+		return nil, nil
+	}
+	return &model.InstallAttributionResponse{
+		DeepLink:      params.Get("link"),
+		RequestedLink: shortLink.String(),
+		MatchType:     model.MatchUnique,
+		Confidence:    1,
+		UTMSource:     values.Get("utm_source"),
+		UTMMedium:     values.Get("utm_medium"),
+		UTMCampaign:   values.Get("utm_campaign"),
+	}, nil
+}
+
+type scoredClick struct {
+	click model.Click
+	score float64
+}
+
+func (s *InstallAttributionService) attributeProbabilistic(ctx context.Context, req *model.InstallAttributionRequest, ip string) (*model.InstallAttributionResponse, error) {
+	now := s.now()
+	clicks, err := s.clicks.Since(ctx, req.Platform, now.Add(-s.window))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load clicks: %w", err)
+	}
+
+	candidates := make([]scoredClick, 0, len(clicks))
+	for _, click := range clicks {
+		candidates = append(candidates, scoredClick{click: click, score: s.score(&click, req, ip, now)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].click.CreatedAt.After(candidates[j].click.CreatedAt)
+	})
+
+	if len(candidates) == 0 || candidates[0].score < s.minConfidence {
+		return &model.InstallAttributionResponse{MatchType: model.MatchNone}, nil
+	}
+	best := candidates[0]
+	if len(candidates) > 1 {
+		runnerUp := candidates[1]
+		if runnerUp.click.DeepLink != best.click.DeepLink && best.score-runnerUp.score < ambiguousMatchMargin {
+			log.Debug().Float64("score", best.score).Msg("Declining ambiguous install attribution")
+			return &model.InstallAttributionResponse{MatchType: model.MatchNone}, nil
+		}
+	}
+
+	click, err := s.clicks.Take(ctx, best.click.Token)
+	if errors.Is(err, ErrClickNotFound) {
+		// Another install claimed the click concurrently.
+		return &model.InstallAttributionResponse{MatchType: model.MatchNone}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return clickResponse(click, model.MatchDefault, best.score), nil
+}
+
+func (s *InstallAttributionService) score(click *model.Click, req *model.InstallAttributionRequest, ip string, now time.Time) float64 {
+	score := 0.0
+	if ip != "" && click.IP == ip {
+		score += matchWeightIP
+	}
+	if req.OSVersion != "" && click.OSVersion != "" && sameOSVersion(click.OSVersion, req.OSVersion) {
+		score += matchWeightOSVersion
+	}
+	if req.Screen != "" && strings.EqualFold(strings.TrimSpace(req.Screen), click.Screen) {
+		score += matchWeightScreen
+	}
+	if age := now.Sub(click.CreatedAt); age >= 0 && age < s.window {
+		score += matchWeightRecency * (1 - float64(age)/float64(s.window))
+	}
+	return score
+}
+
+// sameOSVersion compares versions ignoring a trailing ".0", which User-Agents and apps
+// report inconsistently.
+func sameOSVersion(a, b string) bool {
+	return strings.TrimSuffix(a, ".0") == strings.TrimSuffix(b, ".0")
+}
+
+func clickResponse(click *model.Click, matchType string, confidence float64) *model.InstallAttributionResponse {
+	return &model.InstallAttributionResponse{
+		DeepLink:      click.DeepLink,
+		RequestedLink: click.ShortLink,
+		MatchType:     matchType,
+		Confidence:    confidence,
+		UTMSource:     click.UTMSource,
+		UTMMedium:     click.UTMMedium,
+		UTMCampaign:   click.UTMCampaign,
+	}
+}
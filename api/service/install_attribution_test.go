@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+func newTestAttributionService(t *testing.T, now *time.Time) *InstallAttributionService {
+	t.Helper()
+	cfg := &config.Config{AttributionWindow: "1h", AttributionMinConfidence: "0.6", AttributionMaxClicks: "100"}
+	linkService := NewDynamicLinkService(cfg, nil, NewMemoryLinkResolver(map[string]string{
+		"example.page.link/abc": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fabc&apn=com.example",
+	}))
+	s, err := NewInstallAttributionService(cfg, linkService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return *now }
+	s.clicks.(*MemoryClickStore).now = s.now
+	return s
+}
+
+func TestInstallAttributionReferrer(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestAttributionService(t, &now)
+	ctx := context.Background()
+
+	token, err := s.RecordClick(ctx, &model.Click{Platform: "android", ShortLink: "https://example.page.link/abc", DeepLink: "https://example.com/abc", UTMSource: "news"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	referrer := "tracking_id=https://example.page.link/abc&click_id=" + token
+	response, err := s.Attribute(ctx, &model.InstallAttributionRequest{Platform: "android", InstallReferrer: referrer}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.MatchType != model.MatchUnique || response.DeepLink != "https://example.com/abc" || response.UTMSource != "news" {
+		t.Errorf("Attribute() = %+v, want unique match with click data", response)
+	}
+
+	// The click is consumed; the short link in the referrer still identifies the deep link.
+	response, err = s.Attribute(ctx, &model.InstallAttributionRequest{Platform: "android", InstallReferrer: referrer}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.MatchType != model.MatchUnique || response.DeepLink != "https://example.com/abc" || response.RequestedLink != "https://example.page.link/abc" {
+		t.Errorf("Attribute() = %+v, want unique match from tracking_id", response)
+	}
+
+	response, err = s.Attribute(ctx, &model.InstallAttributionRequest{Platform: "android", InstallReferrer: "utm_source=google-play&utm_medium=organic"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.MatchType != model.MatchNone {
+		t.Errorf("Attribute() = %+v, want no match for organic install", response)
+	}
+}
+
+func TestInstallAttributionProbabilistic(t *testing.T) {
+	tests := []struct {
+		name              string
+		clicks            []model.Click
+		clickAge          time.Duration
+		request           model.InstallAttributionRequest
+		ip                string
+		expectedMatchType string
+		expectedDeepLink  string
+	}{
+		{
+			name: "ip, os version and screen match",
+			clicks: []model.Click{
+				{Platform: "ios", DeepLink: "https://example.com/a", IP: "198.51.100.7", OSVersion: "17.4", Screen: "390x844@3"},
+				{Platform: "ios", DeepLink: "https://example.com/b", IP: "203.0.113.9", OSVersion: "17.4", Screen: "390x844@3"},
+			},
+			request:           model.InstallAttributionRequest{Platform: "ios", OSVersion: "17.4.0", Screen: "390x844@3"},
+			ip:                "198.51.100.7",
+			expectedMatchType: model.MatchDefault,
+			expectedDeepLink:  "https://example.com/a",
+		},
+		{
+			name: "ip and screen match",
+			clicks: []model.Click{
+				{Platform: "ios", DeepLink: "https://example.com/a", IP: "198.51.100.7", OSVersion: "16.0", Screen: "390x844@3"},
+			},
+			request:           model.InstallAttributionRequest{Platform: "ios", OSVersion: "17.4", Screen: "390x844@3"},
+			ip:                "198.51.100.7",
+			expectedMatchType: model.MatchDefault,
+			expectedDeepLink:  "https://example.com/a",
+		},
+		{
+			name: "only ip matches",
+			clicks: []model.Click{
+				{Platform: "ios", DeepLink: "https://example.com/a", IP: "198.51.100.7", OSVersion: "16.0", Screen: "375x667@2"},
+			},
+			request:           model.InstallAttributionRequest{Platform: "ios", OSVersion: "17.4", Screen: "390x844@3"},
+			ip:                "198.51.100.7",
+			expectedMatchType: model.MatchNone,
+		},
+		{
+			name: "different ip",
+			clicks: []model.Click{
+				{Platform: "ios", DeepLink: "https://example.com/a", IP: "203.0.113.9", OSVersion: "17.4", Screen: "390x844@3"},
+			},
+			request:           model.InstallAttributionRequest{Platform: "ios", OSVersion: "17.4", Screen: "390x844@3"},
+			ip:                "198.51.100.7",
+			expectedMatchType: model.MatchNone,
+		},
+		{
+			name: "outside window",
+			clicks: []model.Click{
+				{Platform: "ios", DeepLink: "https://example.com/a", IP: "198.51.100.7", OSVersion: "17.4", Screen: "390x844@3"},
+			},
+			clickAge:          2 * time.Hour,
+			request:           model.InstallAttributionRequest{Platform: "ios", OSVersion: "17.4", Screen: "390x844@3"},
+			ip:                "198.51.100.7",
+			expectedMatchType: model.MatchNone,
+		},
+		{
+			name: "other platform",
+			clicks: []model.Click{
+				{Platform: "android", DeepLink: "https://example.com/a", IP: "198.51.100.7"},
+			},
+			request:           model.InstallAttributionRequest{Platform: "ios"},
+			ip:                "198.51.100.7",
+			expectedMatchType: model.MatchNone,
+		},
+		{
+			name: "ambiguous deep links",
+			clicks: []model.Click{
+				{Platform: "ios", DeepLink: "https://example.com/a", IP: "198.51.100.7"},
+				{Platform: "ios", DeepLink: "https://example.com/b", IP: "198.51.100.7"},
+			},
+			request:           model.InstallAttributionRequest{Platform: "ios", OSVersion: "17.4"},
+			ip:                "198.51.100.7",
+			expectedMatchType: model.MatchNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+			clickTime := now.Add(-tt.clickAge)
+			s := newTestAttributionService(t, &clickTime)
+			ctx := context.Background()
+
+			for _, click := range tt.clicks {
+				if _, err := s.RecordClick(ctx, &click); err != nil {
+					t.Fatal(err)
+				}
+			}
+			now = now.Add(time.Minute)
+			s.now = func() time.Time { return now }
+			s.clicks.(*MemoryClickStore).now = s.now
+
+			response, err := s.Attribute(ctx, &tt.request, tt.ip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.MatchType != tt.expectedMatchType || response.DeepLink != tt.expectedDeepLink {
+				t.Errorf("Attribute() = %+v, want match %s for %q", response, tt.expectedMatchType, tt.expectedDeepLink)
+			}
+		})
+	}
+}
+
+func TestInstallAttributionInvalidPlatform(t *testing.T) {
+	now := time.Now()
+	s := newTestAttributionService(t, &now)
+
+	_, err := s.Attribute(context.Background(), &model.InstallAttributionRequest{Platform: "windows"}, "")
+	if !errors.Is(err, ErrInvalidAttributionRequest) {
+		t.Errorf("Attribute() error = %v, want ErrInvalidAttributionRequest", err)
+	}
+}
+
+func TestMemoryClickStoreCapacity(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryClickStore(time.Hour, 2)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, token := range []string{"a", "b", "c"} {
+		if err := store.Record(ctx, &model.Click{Token: token, Platform: "ios", CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.Take(ctx, "a"); !errors.Is(err, ErrClickNotFound) {
+		t.Errorf("Take(a) error = %v, want ErrClickNotFound", err)
+	}
+	clicks, err := store.Since(ctx, "ios", now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clicks) != 2 {
+		t.Errorf("Since() returned %d clicks, want 2", len(clicks))
+	}
+}
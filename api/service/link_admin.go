@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+func (s *ShortLinkService) GetLink(ctx context.Context, domain, shortCode string) (*model.DynamicLink, error) {
+	return s.store.Get(ctx, domain, shortCode)
+}
+
+func (s *ShortLinkService) ListLinks(ctx context.Context, filter model.LinkFilter) (*model.ListLinksResponse, error) {
+	links, nextPageToken, err := s.store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ListLinksResponse{Links: links, NextPageToken: nextPageToken}, nil
+}
+
+// putLinkAttempts bounds how often PutLink switches between creating and updating while
+// concurrent requests create and delete the same link.
+const putLinkAttempts = 3
+
+// PutLink replaces the long link and activation window behind domain/shortCode, creating the
+// link if it does not exist. The boolean result reports whether the link was created.
+func (s *ShortLinkService) PutLink(ctx context.Context, domain, shortCode string, req *model.PutLinkRequest) (*model.DynamicLink, bool, error) {
+	longLink, err := parseLongDynamicLink(req.LongLink)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := validateLinkWindow(req.NotBefore, req.ExpiresAt); err != nil {
+		return nil, false, err
+	}
+	if !strings.EqualFold(longLink.Host, domain) {
+		return nil, false, fmt.Errorf("%w: long link domain %s does not match %s", ErrInvalidShortLinkRequest, longLink.Host, domain)
+	}
+
+	now := s.now().UTC()
+	// Another request may create or delete the link between the lookup and the write, so a
+	// lost race is retried the other way round.
+	for attempt := 1; ; attempt++ {
+		existing, err := s.store.Get(ctx, domain, shortCode)
+		if errors.Is(err, ErrLinkNotFound) {
+			link := &model.DynamicLink{
+				Domain:    domain,
+				ShortCode: shortCode,
+				LongLink:  longLink.String(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				NotBefore: req.NotBefore,
+				ExpiresAt: req.ExpiresAt,
+			}
+			err = s.store.Create(ctx, link)
+			if err == nil {
+				s.linkService.InvalidateLink(domain, shortCode)
+				return link, true, nil
+			}
+			if errors.Is(err, ErrLinkExists) && attempt < putLinkAttempts {
+				continue
+			}
+			return nil, false, err
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		existing.LongLink = longLink.String()
+		existing.NotBefore = req.NotBefore
+		existing.ExpiresAt = req.ExpiresAt
+		existing.UpdatedAt = now
+		err = s.update(ctx, existing)
+		if err == nil {
+			return existing, false, nil
+		}
+		if errors.Is(err, ErrLinkNotFound) && attempt < putLinkAttempts {
+			continue
+		}
+		return nil, false, err
+	}
+}
+
+// PatchLink changes individual parameters of a stored long link while keeping its short code.
+func (s *ShortLinkService) PatchLink(ctx context.Context, domain, shortCode string, patch *model.LinkPatch) (*model.DynamicLink, error) {
+	link, err := s.store.Get(ctx, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	longLink, err := url.Parse(link.LongLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored long link: %w", err)
+	}
+
+	params := longLink.Query()
+	for key, value := range map[string]*string{
+		"link": patch.Link,
+		"ifl":  patch.IFL,
+		"afl":  patch.AFL,
+		"ofl":  patch.OFL,
+		"st":   patch.ST,
+		"sd":   patch.SD,
+		"si":   patch.SI,
+	} {
+		switch {
+		case value == nil:
+		case *value == "":
+			params.Del(key)
+		default:
+			params.Set(key, *value)
+		}
+	}
+	longLink.RawQuery = params.Encode()
+
+	validated, err := parseLongDynamicLink(longLink.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"ifl", "afl", "ofl", "si"} {
+		if value := params.Get(key); value != "" {
+			if parsed, err := url.Parse(value); err != nil || !parsed.IsAbs() {
+				return nil, fmt.Errorf("%w: %s is not an absolute URL", ErrInvalidShortLinkRequest, key)
+			}
+		}
+	}
+
+	for target, value := range map[**time.Time]*string{
+		&link.NotBefore: patch.NotBefore,
+		&link.ExpiresAt: patch.ExpiresAt,
+	} {
+		switch {
+		case value == nil:
+		case *value == "":
+			*target = nil
+		default:
+			parsed, err := time.Parse(time.RFC3339, *value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not an RFC 3339 timestamp", ErrInvalidShortLinkRequest, *value)
+			}
+			*target = &parsed
+		}
+	}
+	if err := validateLinkWindow(link.NotBefore, link.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	link.LongLink = validated.String()
+	link.UpdatedAt = s.now().UTC()
+	if err := s.update(ctx, link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *ShortLinkService) DeleteLink(ctx context.Context, domain, shortCode string) error {
+	if err := s.store.Delete(ctx, domain, shortCode); err != nil {
+		return err
+	}
+	s.linkService.InvalidateLink(domain, shortCode)
+	log.Info().Str("domain", domain).Str("shortCode", shortCode).Msg("Deleted short link")
+	return nil
+}
+
+// ImportLinks ingests an exported link file into the store; see LinkImporter.
+func (s *ShortLinkService) ImportLinks(ctx context.Context, r io.Reader, options ImportOptions) (*model.ImportReport, error) {
+	return NewLinkImporter(s.store, s.linkService.InvalidateLink).Import(ctx, r, options)
+}
+
+func validateLinkWindow(notBefore, expiresAt *time.Time) error {
+	if notBefore != nil && expiresAt != nil && !notBefore.Before(*expiresAt) {
+		return fmt.Errorf("%w: notBefore must be before expiresAt", ErrInvalidShortLinkRequest)
+	}
+	return nil
+}
+
+func (s *ShortLinkService) update(ctx context.Context, link *model.DynamicLink) error {
+	if err := s.store.Update(ctx, link); err != nil {
+		return err
+	}
+	s.linkService.InvalidateLink(link.Domain, link.ShortCode)
+	log.Info().Str("domain", link.Domain).Str("shortCode", link.ShortCode).Msg("Updated short link")
+	return nil
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"dynamic-link-redirect/api/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	importBatchSize = 1000
+	maxImportIssues = 1000
+)
+
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"1/2/2006 15:04:05",
+	"1/2/2006",
+}
+
+type ImportOptions struct {
+	// Format is "csv" or "json" (a JSON array or JSON lines). Empty detects it from the first character.
+	Format string
+	// SkipRows resumes an earlier import by skipping that many data rows.
+	SkipRows int64
+	// Checkpoint is called after each committed batch with the number of rows consumed so far.
+	Checkpoint func(rows int64) error
+}
+
+type importRow struct {
+	number       int64
+	shortLink    string
+	longLink     string
+	creationTime string
+	// invalid is set when the row itself could not be parsed.
+	invalid string
+}
+
+// LinkImporter ingests exported Firebase Dynamic Links into a LinkStore.
+type LinkImporter struct {
+	store      LinkStore
+	invalidate func(domain, shortCode string)
+	now        func() time.Time
+}
+
+// NewLinkImporter creates an importer. invalidate, if not nil, is called for every imported link
+// so stale negative cache entries do not hide it.
+func NewLinkImporter(store LinkStore, invalidate func(domain, shortCode string)) *LinkImporter {
+	return &LinkImporter{store: store, invalidate: invalidate, now: time.Now}
+}
+
+func (i *LinkImporter) Import(ctx context.Context, r io.Reader, options ImportOptions) (*model.ImportReport, error) {
+	reader := bufio.NewReaderSize(r, 64<<10)
+
+	format := detectImportFormat(reader)
+	if options.Format != "" {
+		format = options.Format
+	}
+
+	var next func() (*importRow, error)
+	switch format {
+	case "csv":
+		rows, err := newCSVImportRows(reader)
+		if err != nil {
+			return nil, err
+		}
+		next = rows
+	case "json":
+		next = newJSONImportRows(reader)
+	default:
+		return nil, fmt.Errorf("%w: unsupported import format %q", ErrInvalidShortLinkRequest, format)
+	}
+
+	report := &model.ImportReport{}
+	batch := make([]*model.DynamicLink, 0, importBatchSize)
+	batchRows := make([]*importRow, 0, importBatchSize)
+
+	flush := func() error {
+		if err := i.commit(ctx, batch, batchRows, report); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		batchRows = batchRows[:0]
+		if options.Checkpoint != nil {
+			if err := options.Checkpoint(report.LastRow); err != nil {
+				return fmt.Errorf("failed to write checkpoint: %w", err)
+			}
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to read import row %d: %w", report.LastRow+1, err)
+		}
+
+		if row.number <= options.SkipRows {
+			report.LastRow = row.number
+			continue
+		}
+
+		link, reason := i.parseRow(row)
+		if reason != "" {
+			report.Invalid++
+			report.AddIssue(model.ImportIssue{Row: row.number, ShortLink: row.shortLink, Reason: reason}, maxImportIssues)
+		}
+
+		batch = append(batch, link)
+		batchRows = append(batchRows, row)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return report, err
+		}
+	}
+
+	log.Info().
+		Int64("rows", report.LastRow).
+		Int64("imported", report.Imported).
+		Int64("duplicates", report.Duplicates).
+		Int64("conflicts", report.Conflicts).
+		Int64("invalid", report.Invalid).
+		Msg("Finished link import")
+	return report, nil
+}
+
+// commit stores a batch. Invalid rows are carried as nil links so LastRow stays contiguous.
+func (i *LinkImporter) commit(ctx context.Context, batch []*model.DynamicLink, rows []*importRow, report *model.ImportReport) error {
+	errs := createLinks(ctx, i.store, batch)
+
+	for n, link := range batch {
+		row := rows[n]
+		report.LastRow = row.number
+		if link == nil {
+			continue
+		}
+
+		err := errs[n]
+		switch {
+		case err == nil:
+			report.Imported++
+			if i.invalidate != nil {
+				i.invalidate(link.Domain, link.ShortCode)
+			}
+		case errors.Is(err, ErrLinkExists):
+			existing, getErr := i.store.Get(ctx, link.Domain, link.ShortCode)
+			if getErr == nil && existing.LongLink == link.LongLink {
+				report.Duplicates++
+				report.AddIssue(model.ImportIssue{Row: row.number, ShortLink: row.shortLink, Reason: "duplicate"}, maxImportIssues)
+			} else {
+				report.Conflicts++
+				report.AddIssue(model.ImportIssue{Row: row.number, ShortLink: row.shortLink, Reason: "short link already exists with a different long link"}, maxImportIssues)
+			}
+		default:
+			return fmt.Errorf("failed to store import row %d: %w", row.number, err)
+		}
+	}
+	return nil
+}
+
+func (i *LinkImporter) parseRow(row *importRow) (*model.DynamicLink, string) {
+	if row.invalid != "" {
+		return nil, row.invalid
+	}
+
+	shortLink, err := url.Parse(strings.TrimSpace(row.shortLink))
+	if err != nil || shortLink.Host == "" {
+		return nil, "short link is not an absolute URL"
+	}
+	shortCode := strings.Trim(shortLink.Path, "/")
+	if shortCode == "" || strings.Contains(shortCode, "/") {
+		return nil, "short link must have exactly one path segment"
+	}
+
+	longLink := strings.TrimSpace(row.longLink)
+	if longLink == "" {
+		return nil, "long link is empty"
+	}
+	if _, err := longLinkQueryParams(longLink); err != nil {
+		return nil, err.Error()
+	}
+
+	createdAt := i.now().UTC()
+	if row.creationTime != "" {
+		createdAt, err = parseImportTime(row.creationTime)
+		if err != nil {
+			return nil, fmt.Sprintf("invalid creation time %q", row.creationTime)
+		}
+	}
+
+	return &model.DynamicLink{
+		Domain:    shortLink.Host,
+		ShortCode: shortCode,
+		LongLink:  longLink,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}, ""
+}
+
+// batchLinkCreator is implemented by stores that can persist many links at once more cheaply
+// than one Create call each. The returned slice has one entry per link; nil links are skipped.
+type batchLinkCreator interface {
+	CreateBatch(ctx context.Context, links []*model.DynamicLink) []error
+}
+
+func createLinks(ctx context.Context, store LinkStore, links []*model.DynamicLink) []error {
+	if batcher, ok := store.(batchLinkCreator); ok {
+		return batcher.CreateBatch(ctx, links)
+	}
+	errs := make([]error, len(links))
+	for n, link := range links {
+		if link != nil {
+			errs[n] = store.Create(ctx, link)
+		}
+	}
+	return errs
+}
+
+func parseImportTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	for _, layout := range importTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time format")
+}
+
+func detectImportFormat(reader *bufio.Reader) string {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return "csv"
+		}
+		switch {
+		case b[0] == '[' || b[0] == '{':
+			return "json"
+		case unicode.IsSpace(rune(b[0])) || b[0] == 0xEF || b[0] == 0xBB || b[0] == 0xBF:
+			// Skip leading whitespace and a UTF-8 byte order mark.
+			reader.ReadByte()
+		default:
+			return "csv"
+		}
+	}
+}
+
+// importColumn maps the column and field names used by Firebase exports and common
+// spreadsheets onto the three values the importer needs.
+func importColumn(name string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+
+	switch normalized {
+	case "shortlink", "shortdynamiclink", "shorturl":
+		return "shortLink"
+	case "longlink", "longdynamiclink", "link", "longurl":
+		return "longLink"
+	case "creationtime", "createdat", "created", "creationdate", "createtime":
+		return "creationTime"
+	}
+	return ""
+}
+
+func newCSVImportRows(reader io.Reader) (func() (*importRow, error), error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidShortLinkRequest, err)
+	}
+
+	columns := map[string]int{}
+	for index, name := range header {
+		if column := importColumn(name); column != "" {
+			if _, seen := columns[column]; !seen {
+				columns[column] = index
+			}
+		}
+	}
+	if _, ok := columns["shortLink"]; !ok {
+		return nil, fmt.Errorf("%w: CSV header has no short link column", ErrInvalidShortLinkRequest)
+	}
+	if _, ok := columns["longLink"]; !ok {
+		return nil, fmt.Errorf("%w: CSV header has no long link column", ErrInvalidShortLinkRequest)
+	}
+
+	field := func(record []string, column string) string {
+		index, ok := columns[column]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return record[index]
+	}
+
+	var number int64
+	return func() (*importRow, error) {
+		record, err := csvReader.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			number++
+			return &importRow{number: number, invalid: parseErr.Error()}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		number++
+		return &importRow{
+			number:       number,
+			shortLink:    field(record, "shortLink"),
+			longLink:     field(record, "longLink"),
+			creationTime: field(record, "creationTime"),
+		}, nil
+	}, nil
+}
+
+// newJSONImportRows reads a top-level array element by element, or otherwise one object per
+// line. Elements that are not objects become invalid rows; so do lines that are not valid JSON,
+// while a syntax error inside an array ends the import since the rest cannot be found.
+func newJSONImportRows(reader *bufio.Reader) func() (*importRow, error) {
+	var decoder *json.Decoder
+	started := false
+	var number int64
+
+	next := func() (json.RawMessage, error) {
+		if decoder != nil {
+			if !decoder.More() {
+				return nil, io.EOF
+			}
+			var raw json.RawMessage
+			err := decoder.Decode(&raw)
+			return raw, err
+		}
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				return line, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return func() (*importRow, error) {
+		if !started {
+			started = true
+			if b, err := reader.Peek(1); err == nil && b[0] == '[' {
+				decoder = json.NewDecoder(reader)
+				if _, err := decoder.Token(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		raw, err := next()
+		if err != nil {
+			return nil, err
+		}
+		number++
+
+		var object map[string]any
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return &importRow{number: number, invalid: fmt.Sprintf("invalid JSON object: %v", err)}, nil
+		}
+
+		row := &importRow{number: number}
+		for key, value := range object {
+			var text string
+			switch v := value.(type) {
+			case string:
+				text = v
+			case float64:
+				text = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				continue
+			}
+			switch importColumn(key) {
+			case "shortLink":
+				row.shortLink = text
+			case "longLink":
+				row.longLink = text
+			case "creationTime":
+				row.creationTime = text
+			}
+		}
+		return row, nil
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+func TestLinkImporter(t *testing.T) {
+	tests := []struct {
+		name               string
+		input              string
+		options            ImportOptions
+		expectedImported   int64
+		expectedDuplicates int64
+		expectedConflicts  int64
+		expectedInvalid    int64
+		expectedLastRow    int64
+	}{
+		{
+			name: "csv with firebase column names",
+			input: "\ufeffShort Dynamic Link,Long Dynamic Link,Creation Time\n" +
+				"https://example.page.link/new,https://example.page.link/?link=https%3A%2F%2Fexample.com,2021-03-04T05:06:07Z\n" +
+				"https://example.page.link/existing,https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fexisting,2021-03-04\n" +
+				"https://example.page.link/existing,https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fother,2021-03-04\n" +
+				"not a link,https://example.page.link/?link=https%3A%2F%2Fexample.com,\n" +
+				"https://example.page.link/bad,%%%,\n",
+			expectedImported:   1,
+			expectedDuplicates: 1,
+			expectedConflicts:  1,
+			expectedInvalid:    2,
+			expectedLastRow:    5,
+		},
+		{
+			name: "resume skips rows",
+			input: "shortLink,longLink\n" +
+				"https://example.page.link/a,https://example.page.link/?link=https%3A%2F%2Fexample.com\n" +
+				"https://example.page.link/b,https://example.page.link/?link=https%3A%2F%2Fexample.com\n",
+			options:          ImportOptions{SkipRows: 1},
+			expectedImported: 1,
+			expectedLastRow:  2,
+		},
+		{
+			name:             "json array",
+			input:            ` [{"shortLink": "https://example.page.link/a", "longLink": "https://example.page.link/?link=https%3A%2F%2Fexample.com", "creationTime": 1614834367}]`,
+			expectedImported: 1,
+			expectedLastRow:  1,
+		},
+		{
+			name: "json lines",
+			input: `{"short_link": "https://example.page.link/a", "long_link": "https://example.page.link/?link=https%3A%2F%2Fexample.com"}
+{"short_link": "https://example.page.link/a/b", "long_link": "https://example.page.link/?link=https%3A%2F%2Fexample.com"}
+`,
+			expectedImported: 1,
+			expectedInvalid:  1,
+			expectedLastRow:  2,
+		},
+		{
+			name:             "json array with an element that is not an object",
+			input:            `[["https://example.page.link/a"], {"shortLink": "https://example.page.link/b", "longLink": "https://example.page.link/?link=https%3A%2F%2Fexample.com"}]`,
+			expectedImported: 1,
+			expectedInvalid:  1,
+			expectedLastRow:  2,
+		},
+		{
+			name: "json lines with a malformed line",
+			input: `{"shortLink": "https://example.page.link/a", "longLink": "https://example.page.link/?li
+{"shortLink": "https://example.page.link/b", "longLink": "https://example.page.link/?link=https%3A%2F%2Fexample.com"}
+`,
+			expectedImported: 1,
+			expectedInvalid:  1,
+			expectedLastRow:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryLinkStore()
+			store.Create(context.Background(), &model.DynamicLink{
+				Domain:    "example.page.link",
+				ShortCode: "existing",
+				LongLink:  "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fexisting",
+			})
+
+			var invalidated []string
+			importer := NewLinkImporter(store, func(domain, shortCode string) {
+				invalidated = append(invalidated, domain+"/"+shortCode)
+			})
+
+			var checkpoints []int64
+			tt.options.Checkpoint = func(rows int64) error {
+				checkpoints = append(checkpoints, rows)
+				return nil
+			}
+
+			report, err := importer.Import(context.Background(), strings.NewReader(tt.input), tt.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if report.Imported != tt.expectedImported || report.Duplicates != tt.expectedDuplicates ||
+				report.Conflicts != tt.expectedConflicts || report.Invalid != tt.expectedInvalid {
+				t.Errorf("report = %+v, want imported %d, duplicates %d, conflicts %d, invalid %d",
+					report, tt.expectedImported, tt.expectedDuplicates, tt.expectedConflicts, tt.expectedInvalid)
+			}
+			if report.LastRow != tt.expectedLastRow {
+				t.Errorf("LastRow = %d, want %d", report.LastRow, tt.expectedLastRow)
+			}
+			if len(checkpoints) == 0 || checkpoints[len(checkpoints)-1] != tt.expectedLastRow {
+				t.Errorf("checkpoints = %v, want last checkpoint %d", checkpoints, tt.expectedLastRow)
+			}
+			if int64(len(invalidated)) != tt.expectedImported {
+				t.Errorf("invalidated = %v, want %d links", invalidated, tt.expectedImported)
+			}
+		})
+	}
+}
+
+func TestLinkImporterCreationTime(t *testing.T) {
+	store := NewMemoryLinkStore()
+	input := "shortLink,longLink,creationTime\nhttps://example.page.link/a,https://example.page.link/?link=https%3A%2F%2Fexample.com,2021-03-04 05:06:07\n"
+
+	if _, err := NewLinkImporter(store, nil).Import(context.Background(), strings.NewReader(input), ImportOptions{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	link, err := store.Get(context.Background(), "example.page.link", "a")
+	if err != nil {
+		t.Fatalf("Failed to get imported link: %v", err)
+	}
+	if expected := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC); !link.CreatedAt.Equal(expected) {
+		t.Errorf("CreatedAt = %v, want %v", link.CreatedAt, expected)
+	}
+}
+
+func TestLinkImporterStoreNamespace(t *testing.T) {
+	cfg := &config.Config{LinkResolver: "store", TenantsFile: filepath.Join(t.TempDir(), "tenants.json")}
+	if err := os.WriteFile(cfg.TenantsFile, []byte(`[{"name": "partner", "hosts": ["partner.page.link", "go.partner.com"], "storeNamespace": "partner.page.link"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemoryLinkStore()
+	input := "shortLink,longLink\nhttps://go.partner.com/abc,https://go.partner.com/?link=https%3A%2F%2Fpartner.com\n"
+	report, err := NewLinkImporter(NewTenantLinkStore(store, tenants), nil).Import(context.Background(), strings.NewReader(input), ImportOptions{})
+	if err != nil || report.Imported != 1 {
+		t.Fatalf("Import() = %+v, %v, want one imported link", report, err)
+	}
+
+	resolver, err := NewLinkResolver(cfg, tenants, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"go.partner.com", "partner.page.link"} {
+		response, err := resolver.Resolve(context.Background(), &url.URL{Scheme: "https", Host: host, Path: "/abc"})
+		if err != nil || response == nil {
+			t.Errorf("Resolve(%s/abc) = %+v, %v, want the imported link", host, response, err)
+		}
+	}
+}
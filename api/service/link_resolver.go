@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+// LinkResolver exchanges a requested short link for its long link.
+// A nil response with a nil error means the link is unknown.
+type LinkResolver interface {
+	Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error)
+}
+
+// NewLinkResolver builds the resolver named by LINK_RESOLVER. tenants may be nil to use the
+// exchange endpoint of the environment configuration for every host and look links up in the
+// store under their own host.
+func NewLinkResolver(cfg *config.Config, tenants *config.Tenants, store LinkStore) (LinkResolver, error) {
+	if tenants == nil {
+		tenants = config.NewTenants(cfg)
+	}
+
+	var resolver LinkResolver
+	switch cfg.LinkResolver {
+	case "exchange":
+		exchangeOptions, err := parseExchangeClientOptions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		// Tenants sharing an endpoint share its client and circuit breaker.
+		exchangeResolvers := map[string]LinkResolver{}
+		tenantResolvers := map[*config.Tenant]LinkResolver{}
+		for _, tenant := range tenants.All() {
+			endpoint := tenant.ExchangeShortLinkEndpoint
+			if _, ok := exchangeResolvers[endpoint]; !ok {
+				exchangeResolvers[endpoint] = NewExchangeLinkResolver(NewExchangeClient(endpoint, exchangeOptions))
+			}
+			tenantResolvers[tenant] = exchangeResolvers[endpoint]
+		}
+		if len(exchangeResolvers) == 1 {
+			resolver = tenantResolvers[tenants.Default()]
+		} else {
+			resolver = &TenantLinkResolver{tenants: tenants, resolvers: tenantResolvers}
+		}
+	case "file":
+		fileResolver, err := NewFileLinkResolver(cfg.LinkResolverFile)
+		if err != nil {
+			return nil, err
+		}
+		resolver = fileResolver
+	case "memory":
+		resolver = NewMemoryLinkResolver(nil)
+	case "store":
+		resolver = NewStoreLinkResolver(NewTenantLinkStore(store, tenants))
+	default:
+		return nil, fmt.Errorf("invalid link resolver: %s", cfg.LinkResolver)
+	}
+
+	resolver = NewCoalescingLinkResolver(resolver)
+
+	cacheOptions, err := parseCacheOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cacheOptions.Size > 0 {
+		resolver = NewCachingLinkResolver(resolver, cacheOptions)
+	}
+
+	return resolver, nil
+}
+
+// TenantLinkResolver hands each link to the resolver of the tenant serving its host.
+type TenantLinkResolver struct {
+	tenants   *config.Tenants
+	resolvers map[*config.Tenant]LinkResolver
+}
+
+func (r *TenantLinkResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	return r.resolvers[r.tenants.ForHost(requestedLink.Host)].Resolve(ctx, requestedLink)
+}
+
+func parseCacheOptions(cfg *config.Config) (CacheOptions, error) {
+	var options CacheOptions
+	var err error
+
+	if options.Size, err = parseOptionalInt(cfg.LinkCacheSize); err != nil {
+		return options, fmt.Errorf("invalid link cache size: %w", err)
+	}
+	if options.TTL, err = parseOptionalDuration(cfg.LinkCacheTTL); err != nil {
+		return options, fmt.Errorf("invalid link cache TTL: %w", err)
+	}
+	if options.NegativeTTL, err = parseOptionalDuration(cfg.LinkCacheNegativeTTL); err != nil {
+		return options, fmt.Errorf("invalid link cache negative TTL: %w", err)
+	}
+	if options.StaleTTL, err = parseOptionalDuration(cfg.LinkCacheStaleTTL); err != nil {
+		return options, fmt.Errorf("invalid link cache stale TTL: %w", err)
+	}
+	return options, nil
+}
+
+func parseExchangeClientOptions(cfg *config.Config) (ExchangeClientOptions, error) {
+	var options ExchangeClientOptions
+	var err error
+
+	if options.Timeout, err = parseOptionalDuration(cfg.ExchangeTimeout); err != nil {
+		return options, fmt.Errorf("invalid exchange timeout: %w", err)
+	}
+	if options.MaxRetries, err = parseOptionalInt(cfg.ExchangeMaxRetries); err != nil {
+		return options, fmt.Errorf("invalid exchange max retries: %w", err)
+	}
+	if options.RetryBackoff, err = parseOptionalDuration(cfg.ExchangeRetryBackoff); err != nil {
+		return options, fmt.Errorf("invalid exchange retry backoff: %w", err)
+	}
+	if options.BreakerThreshold, err = parseOptionalInt(cfg.ExchangeBreakerThreshold); err != nil {
+		return options, fmt.Errorf("invalid exchange breaker threshold: %w", err)
+	}
+	if options.BreakerCooldown, err = parseOptionalDuration(cfg.ExchangeBreakerCooldown); err != nil {
+		return options, fmt.Errorf("invalid exchange breaker cooldown: %w", err)
+	}
+	return options, nil
+}
+
+func parseOptionalInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func parseOptionalDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// linkKey identifies a short link independently of scheme, query and fragment,
+// e.g. "jefit.page.link/abc".
+func linkKey(u *url.URL) string {
+	return strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+}
+
+// MemoryLinkResolver resolves links from an in-memory table keyed by short link.
+type MemoryLinkResolver struct {
+	mu    sync.RWMutex
+	links map[string]string
+}
+
+// NewMemoryLinkResolver builds a resolver from short link → long link pairs.
+// Short links may be given with or without a scheme.
+func NewMemoryLinkResolver(links map[string]string) *MemoryLinkResolver {
+	r := &MemoryLinkResolver{links: make(map[string]string, len(links))}
+	for shortLink, longLink := range links {
+		r.Set(shortLink, longLink)
+	}
+	return r
+}
+
+func (r *MemoryLinkResolver) Set(shortLink, longLink string) {
+	key := normalizeShortLink(shortLink)
+	r.mu.Lock()
+	r.links[key] = longLink
+	r.mu.Unlock()
+}
+
+func (r *MemoryLinkResolver) Delete(shortLink string) {
+	key := normalizeShortLink(shortLink)
+	r.mu.Lock()
+	delete(r.links, key)
+	r.mu.Unlock()
+}
+
+func (r *MemoryLinkResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	r.mu.RLock()
+	longLink, ok := r.links[linkKey(requestedLink)]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	return &model.LongLinkResponseModel{LongLink: longLink}, nil
+}
+
+func normalizeShortLink(shortLink string) string {
+	if !strings.Contains(shortLink, "://") {
+		shortLink = "https://" + shortLink
+	}
+	parsed, err := url.Parse(shortLink)
+	if err != nil {
+		return strings.ToLower(shortLink)
+	}
+	return linkKey(parsed)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+func TestGetQueryParamsFromURL(t *testing.T) {
+	resolver := NewMemoryLinkResolver(map[string]string{
+		"https://example.page.link/abc": "https://example.page.link/?link=https%3A%2F%2Fexample.com&apn=com.example",
+		"example.page.link/empty":       "",
+	})
+	service := NewDynamicLinkService(&config.Config{PreviewUrlStyle: "hyphenated"}, nil, resolver)
+
+	tests := []struct {
+		name        string
+		requestURL  string
+		expectedApn string
+		expectNil   bool
+	}{
+		{
+			name:        "known link",
+			requestURL:  "https://example.page.link/abc",
+			expectedApn: "com.example",
+		},
+		{
+			name:        "known link with query and different scheme",
+			requestURL:  "http://EXAMPLE.page.link/abc?from-preview=true",
+			expectedApn: "com.example",
+		},
+		{
+			name:       "unknown link",
+			requestURL: "https://example.page.link/missing",
+			expectNil:  true,
+		},
+		{
+			name:       "empty long link",
+			requestURL: "https://example.page.link/empty",
+			expectNil:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestURL, err := url.Parse(tt.requestURL)
+			if err != nil {
+				t.Fatalf("Failed to parse request URL: %v", err)
+			}
+
+			params, err := service.GetQueryParamsFromURL(context.Background(), requestURL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if tt.expectNil {
+				if params != nil {
+					t.Errorf("GetQueryParamsFromURL() = %v, want nil", params)
+				}
+				return
+			}
+
+			if params.Get("apn") != tt.expectedApn {
+				t.Errorf("apn = %v, want %v", params.Get("apn"), tt.expectedApn)
+			}
+		})
+	}
+}
+
+func TestNewLinkResolver(t *testing.T) {
+	linkFile := filepath.Join(t.TempDir(), "links.json")
+	if err := os.WriteFile(linkFile, []byte(`{"example.page.link/abc": "https://example.page.link/?link=https://example.com"}`), 0o644); err != nil {
+		t.Fatalf("Failed to write link file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		cfg         config.Config
+		expectError bool
+	}{
+		{name: "exchange", cfg: config.Config{LinkResolver: "exchange"}},
+		{name: "memory", cfg: config.Config{LinkResolver: "memory"}},
+		{name: "store", cfg: config.Config{LinkResolver: "store"}},
+		{name: "file", cfg: config.Config{LinkResolver: "file", LinkResolverFile: linkFile}},
+		{name: "missing file", cfg: config.Config{LinkResolver: "file", LinkResolverFile: filepath.Join(t.TempDir(), "missing.json")}, expectError: true},
+		{name: "invalid resolver", cfg: config.Config{LinkResolver: "invalid"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewLinkResolver(&tt.cfg, nil, NewMemoryLinkStore())
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resolver == nil {
+				t.Error("Expected a resolver but got nil")
+			}
+		})
+	}
+}
+
+func TestNewLinkResolverTenants(t *testing.T) {
+	exchangeServer := func(longLink string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(model.LongLinkResponseModel{LongLink: longLink})
+		}))
+	}
+	defaultServer := exchangeServer("https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fdefault")
+	defer defaultServer.Close()
+	partnerServer := exchangeServer("https://partner.page.link/?link=https%3A%2F%2Fpartner.com%2Fpartner")
+	defer partnerServer.Close()
+
+	cfg := &config.Config{
+		LinkResolver:              "exchange",
+		ExchangeShortLinkEndpoint: defaultServer.URL,
+		PreviewUrlStyle:           "hyphenated",
+		TenantsFile:               filepath.Join(t.TempDir(), "tenants.json"),
+	}
+	tenantsJSON := `[{"name": "partner", "hosts": ["partner.page.link"], "exchangeShortLinkEndpoint": "` + partnerServer.URL + `", "storeNamespace": "partner.page.link"},
+		{"name": "alias", "hosts": ["go.partner.com"], "storeNamespace": "partner.page.link"}]`
+	if err := os.WriteFile(cfg.TenantsFile, []byte(tenantsJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := NewLinkResolver(cfg, tenants, NewMemoryLinkStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]string{
+		"example.page.link": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fdefault",
+		"partner.page.link": "https://partner.page.link/?link=https%3A%2F%2Fpartner.com%2Fpartner",
+	} {
+		response, err := resolver.Resolve(context.Background(), &url.URL{Scheme: "https", Host: host, Path: "/abc"})
+		if err != nil || response == nil || response.LongLink != want {
+			t.Errorf("Resolve(%s) = %+v, %v, want %s", host, response, err, want)
+		}
+	}
+
+	// Every host of a store namespace serves the links stored under it.
+	store := NewMemoryLinkStore()
+	if err := store.Create(context.Background(), &model.DynamicLink{Domain: "partner.page.link", ShortCode: "abc", LongLink: "https://partner.page.link/?link=https%3A%2F%2Fpartner.com"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg.LinkResolver = "store"
+	resolver, err = NewLinkResolver(cfg, tenants, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := resolver.Resolve(context.Background(), &url.URL{Scheme: "https", Host: "go.partner.com", Path: "/abc"})
+	if err != nil || response == nil {
+		t.Errorf("Resolve(go.partner.com/abc) = %+v, %v, want the partner.page.link link", response, err)
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+// ErrInvalidLinkStatsRequest wraps validation failures of a link stats query.
+var ErrInvalidLinkStatsRequest = errors.New("invalid link stats request")
+
+type linkStatsKey struct {
+	platform string
+	event    string
+}
+
+// LinkStats is a click event sink that keeps daily counts per short link, platform and event
+// type for the linkStats API. Counts live in the memory of this process only: they are lost on
+// restart, every replica answers with its own share of the traffic, and they cover the last
+// retentionDays days and lag behind redirects by up to the pipeline's flush interval.
+type LinkStats struct {
+	retentionDays int
+	now           func() time.Time
+
+	mu sync.RWMutex
+	// links maps a short link key such as "example.page.link/abc" to its counts by UTC day.
+	links map[string]map[int64]map[linkStatsKey]int64
+	// removed is the last day dropped by prune.
+	removed int64
+}
+
+func NewLinkStats(retentionDays int) *LinkStats {
+	return &LinkStats{
+		retentionDays: retentionDays,
+		now:           time.Now,
+		links:         make(map[string]map[int64]map[linkStatsKey]int64),
+	}
+}
+
+func (s *LinkStats) Name() string {
+	return "linkStats"
+}
+
+func (s *LinkStats) Write(ctx context.Context, events []model.ClickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	today := s.day(s.now())
+	s.prune(today)
+
+	for i := range events {
+		event := &events[i]
+		if event.ShortCode == "" {
+			continue
+		}
+		day := s.day(event.Timestamp)
+		if day <= today-int64(s.retentionDays) {
+			continue
+		}
+
+		key := strings.ToLower(event.Domain) + "/" + event.ShortCode
+		platform := statsPlatform(event.Platform)
+		for _, statsEvent := range statsEvents(event) {
+			days, ok := s.links[key]
+			if !ok {
+				days = make(map[int64]map[linkStatsKey]int64)
+				s.links[key] = days
+			}
+			counts, ok := days[day]
+			if !ok {
+				counts = make(map[linkStatsKey]int64)
+				days[day] = counts
+			}
+			counts[linkStatsKey{platform: platform, event: statsEvent}]++
+		}
+	}
+	return nil
+}
+
+func (s *LinkStats) Close() error {
+	return nil
+}
+
+// Query returns the counts of shortLink over the last durationDays days, including today, with
+// zero counts left out. shortLink is an absolute URL such as "https://example.page.link/abc".
+func (s *LinkStats) Query(shortLink string, durationDays int) ([]model.LinkEventStat, error) {
+	if durationDays <= 0 {
+		return nil, fmt.Errorf("%w: durationDays must be a positive number", ErrInvalidLinkStatsRequest)
+	}
+	parsedLink, err := url.Parse(shortLink)
+	if err != nil || !parsedLink.IsAbs() || parsedLink.Host == "" {
+		return nil, fmt.Errorf("%w: short link must be an absolute URL", ErrInvalidLinkStatsRequest)
+	}
+	shortCode := strings.Trim(parsedLink.Path, "/")
+	if shortCode == "" {
+		return nil, fmt.Errorf("%w: short link has no path", ErrInvalidLinkStatsRequest)
+	}
+
+	today := s.day(s.now())
+	since := today - int64(durationDays)
+
+	totals := map[linkStatsKey]int64{}
+	s.mu.RLock()
+	for day, counts := range s.links[strings.ToLower(parsedLink.Host)+"/"+shortCode] {
+		if day <= since {
+			continue
+		}
+		for key, count := range counts {
+			totals[key] += count
+		}
+	}
+	s.mu.RUnlock()
+
+	stats := make([]model.LinkEventStat, 0, len(totals))
+	for key, count := range totals {
+		stats = append(stats, model.LinkEventStat{Platform: key.platform, Count: count, Event: key.event})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Platform != stats[j].Platform {
+			return stats[i].Platform < stats[j].Platform
+		}
+		return stats[i].Event < stats[j].Event
+	})
+	return stats, nil
+}
+
+// prune drops the days that fell out of the retention window. It runs at most once a day.
+func (s *LinkStats) prune(today int64) {
+	cutoff := today - int64(s.retentionDays)
+	if cutoff <= s.removed {
+		return
+	}
+	for key, days := range s.links {
+		for day := range days {
+			if day <= cutoff {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(s.links, key)
+		}
+	}
+	s.removed = cutoff
+}
+
+func (s *LinkStats) day(t time.Time) int64 {
+	return t.Unix() / secondsPerDay
+}
+
+func statsPlatform(platform string) string {
+	switch platform {
+	case "android":
+		return model.StatsPlatformAndroid
+	case "ios":
+		return model.StatsPlatformIOS
+	case "web":
+		return model.StatsPlatformDesktop
+	}
+	return model.StatsPlatformOther
+}
+
+// statsEvents maps a click event onto the Firebase event types it counts as. A click is counted
+// once, on the request that reached the user: the preview page, or the redirect when the flow has
+// none. Intermediate hops, crawlers and failed lookups are not counted.
+func statsEvents(event *model.ClickEvent) []string {
+	switch event.Decision {
+	case "afl", "ifl", "ipfl", "ofl", "link",
+		model.DecisionPlayStore, model.DecisionAppStore, model.DecisionIntent, model.DecisionDefaultRedirect:
+		// The preview page already counted the click of a request coming back from it.
+		if event.PreviewShown {
+			return []string{model.StatsEventRedirect}
+		}
+		return []string{model.StatsEventClick, model.StatsEventRedirect}
+	case model.DecisionEscapePage, model.DecisionDefaultPage, model.DecisionExpiredRedirect, model.DecisionGonePage:
+		if event.PreviewShown {
+			return nil
+		}
+		return []string{model.StatsEventClick}
+	case model.DecisionPreviewPage:
+		return []string{model.StatsEventClick}
+	case model.DecisionAppFirstOpen:
+		// The app reports its first launch; an attributed first launch is also the install.
+		return []string{model.StatsEventAppInstall, model.StatsEventAppFirstOpen}
+	case model.DecisionAppReOpen:
+		return []string{model.StatsEventAppReOpen}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+)
+
+func TestLinkStats(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	stats := NewLinkStats(30)
+	stats.now = func() time.Time { return now }
+
+	event := func(daysAgo int, platform, decision string, previewShown bool) model.ClickEvent {
+		return model.ClickEvent{
+			Timestamp:    now.AddDate(0, 0, -daysAgo),
+			Domain:       "Example.page.link",
+			ShortCode:    "abc",
+			Platform:     platform,
+			Decision:     decision,
+			PreviewShown: previewShown,
+		}
+	}
+	err := stats.Write(context.Background(), []model.ClickEvent{
+		event(0, "android", model.DecisionPreviewPage, true),
+		event(0, "android", model.DecisionPlayStore, true),
+		event(0, "android", model.DecisionAppFirstOpen, false),
+		event(1, "ios", "ifl", false),
+		event(1, "ios", model.DecisionPreviewRedirect, false),
+		event(2, "web", "ofl", false),
+		event(2, "bot", model.DecisionSocialPage, false),
+		event(5, "ios", model.DecisionAppReOpen, false),
+		event(40, "web", "ofl", false),
+		{Timestamp: now, Domain: "example.page.link", Platform: "web", Decision: "link"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		durationDays int
+		want         []model.LinkEventStat
+	}{
+		{
+			name:         "today",
+			durationDays: 1,
+			want: []model.LinkEventStat{
+				{Platform: "ANDROID", Count: 1, Event: "APP_FIRST_OPEN"},
+				{Platform: "ANDROID", Count: 1, Event: "APP_INSTALL"},
+				{Platform: "ANDROID", Count: 1, Event: "CLICK"},
+				{Platform: "ANDROID", Count: 1, Event: "REDIRECT"},
+			},
+		},
+		{
+			name:         "week",
+			durationDays: 7,
+			want: []model.LinkEventStat{
+				{Platform: "ANDROID", Count: 1, Event: "APP_FIRST_OPEN"},
+				{Platform: "ANDROID", Count: 1, Event: "APP_INSTALL"},
+				{Platform: "ANDROID", Count: 1, Event: "CLICK"},
+				{Platform: "ANDROID", Count: 1, Event: "REDIRECT"},
+				{Platform: "DESKTOP", Count: 1, Event: "CLICK"},
+				{Platform: "DESKTOP", Count: 1, Event: "REDIRECT"},
+				{Platform: "IOS", Count: 1, Event: "APP_RE_OPEN"},
+				{Platform: "IOS", Count: 1, Event: "CLICK"},
+				{Platform: "IOS", Count: 1, Event: "REDIRECT"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stats.Query("https://example.page.link/abc", tt.durationDays)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Days past the retention window are dropped once the clock moves on.
+	now = now.AddDate(0, 0, 31)
+	stats.Write(context.Background(), nil)
+	if got, _ := stats.Query("https://example.page.link/abc", 365); len(got) != 0 {
+		t.Errorf("Query() after retention = %+v, want none", got)
+	}
+
+	for _, shortLink := range []string{"abc", "https://example.page.link/", "%zz"} {
+		if _, err := stats.Query(shortLink, 7); !errors.Is(err, ErrInvalidLinkStatsRequest) {
+			t.Errorf("Query(%q) error = %v, want ErrInvalidLinkStatsRequest", shortLink, err)
+		}
+	}
+	if _, err := stats.Query("https://example.page.link/abc", 0); !errors.Is(err, ErrInvalidLinkStatsRequest) {
+		t.Errorf("Query() with zero days error = %v, want ErrInvalidLinkStatsRequest", err)
+	}
+}
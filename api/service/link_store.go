@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+var (
+	ErrLinkNotFound = errors.New("link not found")
+	ErrLinkExists   = errors.New("link already exists")
+)
+
+// LinkStore holds short links created through this service.
+type LinkStore interface {
+	Get(ctx context.Context, domain, shortCode string) (*model.DynamicLink, error)
+	// Create stores a new link and returns ErrLinkExists if its short code is taken.
+	Create(ctx context.Context, link *model.DynamicLink) error
+	// Update replaces an existing link and returns ErrLinkNotFound if there is none.
+	Update(ctx context.Context, link *model.DynamicLink) error
+	Delete(ctx context.Context, domain, shortCode string) error
+	// List returns links matching filter ordered by domain and short code, plus the
+	// token of the next page or "" on the last page.
+	List(ctx context.Context, filter model.LinkFilter) ([]model.DynamicLink, string, error)
+}
+
+func NewLinkStore(cfg *config.Config) (LinkStore, error) {
+	switch cfg.LinkStore {
+	case "memory":
+		return NewMemoryLinkStore(), nil
+	case "file":
+		return OpenFileLinkStore(cfg.LinkStorePath)
+	default:
+		return nil, fmt.Errorf("invalid link store: %s", cfg.LinkStore)
+	}
+}
+
+func storeKey(domain, shortCode string) string {
+	return strings.ToLower(domain) + "/" + shortCode
+}
+
+type MemoryLinkStore struct {
+	mu    sync.RWMutex
+	links map[string]model.DynamicLink
+}
+
+func NewMemoryLinkStore() *MemoryLinkStore {
+	return &MemoryLinkStore{links: make(map[string]model.DynamicLink)}
+}
+
+func (s *MemoryLinkStore) Get(ctx context.Context, domain, shortCode string) (*model.DynamicLink, error) {
+	s.mu.RLock()
+	link, ok := s.links[storeKey(domain, shortCode)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrLinkNotFound
+	}
+	return &link, nil
+}
+
+func (s *MemoryLinkStore) Update(ctx context.Context, link *model.DynamicLink) error {
+	key := storeKey(link.Domain, link.ShortCode)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[key]; !ok {
+		return ErrLinkNotFound
+	}
+	s.links[key] = *link
+	return nil
+}
+
+func (s *MemoryLinkStore) Delete(ctx context.Context, domain, shortCode string) error {
+	key := storeKey(domain, shortCode)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[key]; !ok {
+		return ErrLinkNotFound
+	}
+	delete(s.links, key)
+	return nil
+}
+
+func (s *MemoryLinkStore) List(ctx context.Context, filter model.LinkFilter) ([]model.DynamicLink, string, error) {
+	after := ""
+	if filter.PageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(filter.PageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: invalid page token: %v", ErrInvalidShortLinkRequest, err)
+		}
+		after = string(decoded)
+	}
+
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.links))
+	for key, link := range s.links {
+		if key > after && filter.Matches(&link) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	links := make([]model.DynamicLink, 0, min(len(keys), filter.PageSize))
+	for _, key := range keys {
+		if filter.PageSize > 0 && len(links) == filter.PageSize {
+			break
+		}
+		links = append(links, s.links[key])
+	}
+	s.mu.RUnlock()
+
+	nextPageToken := ""
+	if len(links) < len(keys) {
+		last := links[len(links)-1]
+		nextPageToken = base64.RawURLEncoding.EncodeToString([]byte(storeKey(last.Domain, last.ShortCode)))
+	}
+	return links, nextPageToken, nil
+}
+
+func (s *MemoryLinkStore) Create(ctx context.Context, link *model.DynamicLink) error {
+	key := storeKey(link.Domain, link.ShortCode)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[key]; ok {
+		return ErrLinkExists
+	}
+	s.links[key] = *link
+	return nil
+}
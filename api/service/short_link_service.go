@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidShortLinkRequest wraps every validation failure of a create request.
+var ErrInvalidShortLinkRequest = errors.New("invalid short link request")
+
+const (
+	shortCodeAlphabet      = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	shortSuffixLength      = 4
+	unguessableSuffixLen   = 17
+	shortCodeMaxCollisions = 10
+)
+
+type ShortLinkService struct {
+	config      *config.Config
+	store       LinkStore
+	linkService *DynamicLinkService
+	now         func() time.Time
+}
+
+func NewShortLinkService(config *config.Config, store LinkStore, linkService *DynamicLinkService) *ShortLinkService {
+	return &ShortLinkService{config: config, store: NewTenantLinkStore(store, linkService.tenants), linkService: linkService, now: time.Now}
+}
+
+// CreateShortLink mirrors Firebase's shortLinks.create: it accepts either a long dynamic link
+// or its structured dynamicLinkInfo form and stores it under a freshly generated short code.
+func (s *ShortLinkService) CreateShortLink(ctx context.Context, req *model.CreateShortLinkRequest) (*model.CreateShortLinkResponse, error) {
+	longLink, err := longLinkFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	// Links on domains we do not serve would never resolve, and links on our domains are only
+	// minted for the hosts we were told about.
+	if !s.linkService.ServesHost(longLink.Host) {
+		return nil, fmt.Errorf("%w: domain %s is not served here", ErrInvalidShortLinkRequest, longLink.Hostname())
+	}
+
+	suffixLength := unguessableSuffixLen
+	if req.Suffix != nil {
+		switch req.Suffix.Option {
+		case "SHORT":
+			suffixLength = shortSuffixLength
+		case "UNGUESSABLE", "":
+		default:
+			return nil, fmt.Errorf("%w: unsupported suffix option %q", ErrInvalidShortLinkRequest, req.Suffix.Option)
+		}
+	}
+
+	now := s.now().UTC()
+	link := &model.DynamicLink{
+		Domain:    longLink.Host,
+		LongLink:  longLink.String(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for collisions := 0; ; collisions++ {
+		// Short suffixes grow by one character every few collisions so a crowded domain still converges.
+		link.ShortCode, err = generateShortCode(suffixLength + collisions/3)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.store.Create(ctx, link)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrLinkExists) || collisions >= shortCodeMaxCollisions {
+			return nil, fmt.Errorf("failed to store short link: %w", err)
+		}
+	}
+
+	shortLink := &url.URL{Scheme: longLink.Scheme, Host: link.Domain, Path: "/" + link.ShortCode}
+	previewLink, err := s.linkService.GeneratePreviewURL(shortLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate preview link: %w", err)
+	}
+
+	log.Info().Str("shortLink", shortLink.String()).Msg("Created short link")
+
+	return &model.CreateShortLinkResponse{
+		ShortLink:   shortLink.String(),
+		PreviewLink: previewLink.String(),
+	}, nil
+}
+
+func longLinkFromRequest(req *model.CreateShortLinkRequest) (*url.URL, error) {
+	switch {
+	case req.LongDynamicLink != "" && req.DynamicLinkInfo != nil:
+		return nil, fmt.Errorf("%w: only one of longDynamicLink and dynamicLinkInfo may be set", ErrInvalidShortLinkRequest)
+	case req.LongDynamicLink != "":
+		return parseLongDynamicLink(req.LongDynamicLink)
+	case req.DynamicLinkInfo != nil:
+		return buildLongDynamicLink(req.DynamicLinkInfo)
+	default:
+		return nil, fmt.Errorf("%w: longDynamicLink or dynamicLinkInfo is required", ErrInvalidShortLinkRequest)
+	}
+}
+
+func parseLongDynamicLink(rawLink string) (*url.URL, error) {
+	longLink, err := url.Parse(rawLink)
+	if err != nil || !longLink.IsAbs() || longLink.Host == "" {
+		return nil, fmt.Errorf("%w: longDynamicLink is not an absolute URL", ErrInvalidShortLinkRequest)
+	}
+	if strings.Trim(longLink.Path, "/") != "" {
+		return nil, fmt.Errorf("%w: domain URI prefixes with a path are not supported", ErrInvalidShortLinkRequest)
+	}
+
+	link := longLink.Query().Get("link")
+	if link == "" {
+		return nil, fmt.Errorf("%w: long link is missing the link parameter", ErrInvalidShortLinkRequest)
+	}
+	if parsedLink, err := url.Parse(link); err != nil || !parsedLink.IsAbs() {
+		return nil, fmt.Errorf("%w: link parameter is not an absolute URL", ErrInvalidShortLinkRequest)
+	}
+
+	longLink.Path = "/"
+	longLink.Fragment = ""
+	return longLink, nil
+}
+
+func buildLongDynamicLink(info *model.DynamicLinkInfo) (*url.URL, error) {
+	params := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	set("link", info.Link)
+	if android := info.AndroidInfo; android != nil {
+		set("apn", android.AndroidPackageName)
+		set("afl", android.AndroidFallbackLink)
+		set("amv", android.AndroidMinPackageVersionCode)
+	}
+	if ios := info.IOSInfo; ios != nil {
+		set("ibi", ios.IOSBundleID)
+		set("ifl", ios.IOSFallbackLink)
+		set("ius", ios.IOSCustomScheme)
+		set("ipfl", ios.IOSIpadFallbackLink)
+		set("ipbi", ios.IOSIpadBundleID)
+		set("isi", ios.IOSAppStoreID)
+		set("imv", ios.IOSMinimumVersion)
+	}
+	if navigation := info.NavigationInfo; navigation != nil && navigation.EnableForcedRedirect {
+		set("efr", "1")
+	}
+	if analytics := info.AnalyticsInfo; analytics != nil {
+		if play := analytics.GooglePlayAnalytics; play != nil {
+			set("utm_source", play.UTMSource)
+			set("utm_medium", play.UTMMedium)
+			set("utm_campaign", play.UTMCampaign)
+			set("utm_term", play.UTMTerm)
+			set("utm_content", play.UTMContent)
+			set("gclid", play.Gclid)
+		}
+		if itunes := analytics.ItunesConnectAnalytics; itunes != nil {
+			set("at", itunes.At)
+			set("ct", itunes.Ct)
+			set("mt", itunes.Mt)
+			set("pt", itunes.Pt)
+		}
+	}
+	if social := info.SocialMetaTagInfo; social != nil {
+		set("st", social.SocialTitle)
+		set("sd", social.SocialDescription)
+		set("si", social.SocialImageLink)
+	}
+	if desktop := info.DesktopInfo; desktop != nil {
+		set("ofl", desktop.DesktopFallbackLink)
+	}
+
+	return parseLongDynamicLink(strings.TrimSuffix(info.DomainURIPrefix, "/") + "/?" + params.Encode())
+}
+
+func generateShortCode(length int) (string, error) {
+	alphabetSize := big.NewInt(int64(len(shortCodeAlphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate short code: %w", err)
+		}
+		code[i] = shortCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+func TestCreateShortLink(t *testing.T) {
+	tests := []struct {
+		name               string
+		request            model.CreateShortLinkRequest
+		expectedCodeLength int
+		expectedParams     map[string]string
+		expectError        bool
+	}{
+		{
+			name: "long dynamic link with short suffix",
+			request: model.CreateShortLinkRequest{
+				LongDynamicLink: "https://example.page.link/?link=https%3A%2F%2Fexample.com&apn=com.example",
+				Suffix:          &model.Suffix{Option: "SHORT"},
+			},
+			expectedCodeLength: shortSuffixLength,
+			expectedParams:     map[string]string{"link": "https://example.com", "apn": "com.example"},
+		},
+		{
+			name: "dynamic link info defaults to unguessable suffix",
+			request: model.CreateShortLinkRequest{
+				DynamicLinkInfo: &model.DynamicLinkInfo{
+					DomainURIPrefix:   "https://example.page.link",
+					Link:              "https://example.com/post",
+					IOSInfo:           &model.IOSInfo{IOSBundleID: "com.example.ios", IOSAppStoreID: "123", IOSMinimumVersion: "2.1"},
+					NavigationInfo:    &model.NavigationInfo{EnableForcedRedirect: true},
+					SocialMetaTagInfo: &model.SocialMetaTagInfo{SocialTitle: "Title"},
+				},
+			},
+			expectedCodeLength: unguessableSuffixLen,
+			expectedParams:     map[string]string{"link": "https://example.com/post", "ibi": "com.example.ios", "isi": "123", "imv": "2.1", "efr": "1", "st": "Title"},
+		},
+		{
+			name:        "missing link",
+			request:     model.CreateShortLinkRequest{LongDynamicLink: "https://example.page.link/?apn=com.example"},
+			expectError: true,
+		},
+		{
+			name:        "domain prefix with path",
+			request:     model.CreateShortLinkRequest{LongDynamicLink: "https://example.com/links/?link=https%3A%2F%2Fexample.com"},
+			expectError: true,
+		},
+		{
+			name: "unsupported suffix",
+			request: model.CreateShortLinkRequest{
+				LongDynamicLink: "https://example.page.link/?link=https%3A%2F%2Fexample.com",
+				Suffix:          &model.Suffix{Option: "CUSTOM"},
+			},
+			expectError: true,
+		},
+		{
+			name:        "domain not served",
+			request:     model.CreateShortLinkRequest{LongDynamicLink: "https://evil.example/?link=https%3A%2F%2Fexample.com"},
+			expectError: true,
+		},
+		{
+			name:        "empty request",
+			request:     model.CreateShortLinkRequest{},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{PreviewUrlStyle: "hyphenated", LinkDomains: "example.page.link"}
+			store := NewMemoryLinkStore()
+			service := NewShortLinkService(cfg, store, NewDynamicLinkService(cfg, nil, NewStoreLinkResolver(store)))
+
+			response, err := service.CreateShortLink(context.Background(), &tt.request)
+
+			if tt.expectError {
+				if !errors.Is(err, ErrInvalidShortLinkRequest) {
+					t.Errorf("CreateShortLink() error = %v, want %v", err, ErrInvalidShortLinkRequest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			shortLink, err := url.Parse(response.ShortLink)
+			if err != nil {
+				t.Fatalf("Failed to parse short link: %v", err)
+			}
+			shortCode := strings.TrimPrefix(shortLink.Path, "/")
+			if len(shortCode) != tt.expectedCodeLength {
+				t.Errorf("short code %q has length %d, want %d", shortCode, len(shortCode), tt.expectedCodeLength)
+			}
+			if response.PreviewLink != "https://preview-example.page.link/"+shortCode {
+				t.Errorf("previewLink = %v, want preview of %v", response.PreviewLink, response.ShortLink)
+			}
+
+			stored, err := store.Get(context.Background(), "example.page.link", shortCode)
+			if err != nil {
+				t.Fatalf("Failed to get stored link: %v", err)
+			}
+			longLink, err := url.Parse(stored.LongLink)
+			if err != nil {
+				t.Fatalf("Failed to parse stored long link: %v", err)
+			}
+			for key, value := range tt.expectedParams {
+				if got := longLink.Query().Get(key); got != value {
+					t.Errorf("%s = %v, want %v", key, got, value)
+				}
+			}
+		})
+	}
+}
+
+// racingLinkStore creates a competing link right after PutLink finds none.
+type racingLinkStore struct {
+	LinkStore
+	raced bool
+}
+
+func (s *racingLinkStore) Get(ctx context.Context, domain, shortCode string) (*model.DynamicLink, error) {
+	link, err := s.LinkStore.Get(ctx, domain, shortCode)
+	if errors.Is(err, ErrLinkNotFound) && !s.raced {
+		s.raced = true
+		s.LinkStore.Create(ctx, &model.DynamicLink{Domain: domain, ShortCode: shortCode, LongLink: "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fother"})
+	}
+	return link, err
+}
+
+func TestPutLinkRetriesLostRace(t *testing.T) {
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated"}
+	store := &racingLinkStore{LinkStore: NewMemoryLinkStore()}
+	service := NewShortLinkService(cfg, store, NewDynamicLinkService(cfg, nil, NewStoreLinkResolver(store)))
+
+	longLink := "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost"
+	link, created, err := service.PutLink(context.Background(), "example.page.link", "abc", &model.PutLinkRequest{LongLink: longLink})
+	if err != nil {
+		t.Fatalf("PutLink() error = %v", err)
+	}
+	if created || link.LongLink != longLink {
+		t.Errorf("PutLink() = %+v, created %v; want the competing link updated", link, created)
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"dynamic-link-redirect/api/model"
+)
+
+// StoreLinkResolver resolves links from a LinkStore instead of the exchange backend.
+type StoreLinkResolver struct {
+	store LinkStore
+}
+
+func NewStoreLinkResolver(store LinkStore) *StoreLinkResolver {
+	return &StoreLinkResolver{store: store}
+}
+
+func (r *StoreLinkResolver) Resolve(ctx context.Context, requestedLink *url.URL) (*model.LongLinkResponseModel, error) {
+	shortCode := strings.Trim(requestedLink.Path, "/")
+	link, err := r.store.Get(ctx, requestedLink.Host, shortCode)
+	if errors.Is(err, ErrLinkNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model.LongLinkResponseModel{LongLink: link.LongLink, NotBefore: link.NotBefore, ExpiresAt: link.ExpiresAt}, nil
+}
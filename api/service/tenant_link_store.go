@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/config"
+)
+
+// tenantLinkStore keeps the links of each host under the store namespace of its tenant, so that
+// every host of a tenant creates, finds and deletes the same links. Links read by domain carry
+// the domain they were asked for; listed links carry the namespace they are stored under.
+type tenantLinkStore struct {
+	store   LinkStore
+	tenants *config.Tenants
+}
+
+// NewTenantLinkStore wraps store, unless tenants is nil or store is already wrapped. Everything
+// writing links for the redirect flow goes through it.
+func NewTenantLinkStore(store LinkStore, tenants *config.Tenants) LinkStore {
+	if _, ok := store.(*tenantLinkStore); ok || tenants == nil {
+		return store
+	}
+	return &tenantLinkStore{store: store, tenants: tenants}
+}
+
+func (s *tenantLinkStore) Get(ctx context.Context, domain, shortCode string) (*model.DynamicLink, error) {
+	link, err := s.store.Get(ctx, s.tenants.StoreDomain(domain), shortCode)
+	if err != nil {
+		return nil, err
+	}
+	found := *link
+	found.Domain = domain
+	return &found, nil
+}
+
+func (s *tenantLinkStore) Create(ctx context.Context, link *model.DynamicLink) error {
+	return s.store.Create(ctx, s.stored(link))
+}
+
+// CreateBatch keeps the batching of the wrapped store for imports.
+func (s *tenantLinkStore) CreateBatch(ctx context.Context, links []*model.DynamicLink) []error {
+	stored := make([]*model.DynamicLink, len(links))
+	for n, link := range links {
+		if link != nil {
+			stored[n] = s.stored(link)
+		}
+	}
+	return createLinks(ctx, s.store, stored)
+}
+
+func (s *tenantLinkStore) Update(ctx context.Context, link *model.DynamicLink) error {
+	return s.store.Update(ctx, s.stored(link))
+}
+
+func (s *tenantLinkStore) Delete(ctx context.Context, domain, shortCode string) error {
+	return s.store.Delete(ctx, s.tenants.StoreDomain(domain), shortCode)
+}
+
+func (s *tenantLinkStore) List(ctx context.Context, filter model.LinkFilter) ([]model.DynamicLink, string, error) {
+	if filter.Domain != "" {
+		filter.Domain = s.tenants.StoreDomain(filter.Domain)
+	}
+	return s.store.List(ctx, filter)
+}
+
+// stored returns a copy of link with the domain it is stored under.
+func (s *tenantLinkStore) stored(link *model.DynamicLink) *model.DynamicLink {
+	stored := *link
+	stored.Domain = s.tenants.StoreDomain(link.Domain)
+	return &stored
+}
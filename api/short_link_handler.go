@@ -0,0 +1,66 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+const maxShortLinkRequestBytes = 64 << 10
+
+type ShortLinkHandler struct {
+	service *service.ShortLinkService
+	config  *config.Config
+}
+
+func NewShortLinkHandler(service *service.ShortLinkService, config *config.Config) *ShortLinkHandler {
+	return &ShortLinkHandler{service: service, config: config}
+}
+
+// CreateShortLink serves POST /v1/shortLinks with the Firebase Dynamic Links request and response
+// shape. It is only routed when SHORT_LINKS_API_KEY is set.
+func (h *ShortLinkHandler) CreateShortLink(w http.ResponseWriter, r *http.Request) {
+	if h.config.ShortLinksAPIKey == "" ||
+		subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("key")), []byte(h.config.ShortLinksAPIKey)) != 1 {
+		writeAPIError(w, http.StatusForbidden, "PERMISSION_DENIED", "API key not valid")
+		return
+	}
+
+	var req model.CreateShortLinkRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxShortLinkRequestBytes)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload")
+		return
+	}
+
+	response, err := h.service.CreateShortLink(r.Context(), &req)
+	if errors.Is(err, service.ErrInvalidShortLinkRequest) {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create short link")
+		writeAPIError(w, http.StatusInternalServerError, "INTERNAL", "Internal Server Error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Failed to write JSON response")
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, model.ErrorResponse{Error: model.ErrorBody{Code: status, Message: message, Status: code}})
+}
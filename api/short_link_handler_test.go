@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+)
+
+func TestCreateShortLinkRoundTrip(t *testing.T) {
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated", ShortLinksAPIKey: "secret", LinkDomains: "example.page.link"}
+	store := service.NewMemoryLinkStore()
+	router := newTestRouterWithResolver(cfg, service.NewStoreLinkResolver(store), store)
+
+	body := `{"dynamicLinkInfo": {"domainUriPrefix": "https://example.page.link", "link": "https://example.com/post", "desktopInfo": {"desktopFallbackLink": "https://example.com/desktop"}}, "suffix": {"option": "SHORT"}}`
+
+	req := httptest.NewRequest(http.MethodPost, "https://example.page.link/v1/shortLinks?key=wrong", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status with wrong key = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "https://example.page.link/v1/shortLinks?key=secret", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var response model.CreateShortLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(response.PreviewLink, "https://preview-example.page.link/") {
+		t.Errorf("previewLink = %v, want preview host", response.PreviewLink)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, response.ShortLink, nil)
+	req.Header.Set("User-Agent", testDesktopUA)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if location := rec.Header().Get("Location"); location != "https://example.com/desktop" {
+		t.Errorf("Location = %v, want desktop fallback", location)
+	}
+}
+
+func TestCreateShortLinkWithoutAPIKey(t *testing.T) {
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated", LinkDomains: "example.page.link"}
+	store := service.NewMemoryLinkStore()
+	router := newTestRouterWithResolver(cfg, service.NewStoreLinkResolver(store), store)
+
+	body := `{"longDynamicLink": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost"}`
+	req := httptest.NewRequest(http.MethodPost, "https://example.page.link/v1/shortLinks", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK {
+		t.Errorf("status = %d, want the endpoint to be disabled", rec.Code)
+	}
+}
+
+func TestCreateShortLinkStoreNamespace(t *testing.T) {
+	cfg := &config.Config{
+		PreviewUrlStyle:  "hyphenated",
+		ShortLinksAPIKey: "secret",
+		AdminAPIToken:    "admin-secret",
+		LinkResolver:     "store",
+		LinkCacheSize:    "10",
+		LinkCacheTTL:     "1h",
+		TenantsFile:      filepath.Join(t.TempDir(), "tenants.json"),
+	}
+	tenantsJSON := `[{"name": "partner", "hosts": ["partner.page.link", "go.partner.com"], "storeNamespace": "partner.page.link"}]`
+	if err := os.WriteFile(cfg.TenantsFile, []byte(tenantsJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := service.NewMemoryLinkStore()
+	resolver, err := service.NewLinkResolver(cfg, tenants, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkService := service.NewDynamicLinkService(cfg, tenants, resolver)
+	router := NewRouter(cfg, linkService, service.NewShortLinkService(cfg, store, linkService), RouterOptions{})
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		req.Header.Set("User-Agent", testDesktopUA)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"longDynamicLink": "https://go.partner.com/?link=https%3A%2F%2Fpartner.com%2Fpost&ofl=https%3A%2F%2Fpartner.com%2Fold"}`
+	rec := do(http.MethodPost, "https://go.partner.com/v1/shortLinks?key=secret", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var response model.CreateShortLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	shortCode := strings.TrimPrefix(response.ShortLink, "https://go.partner.com/")
+
+	// The link is stored once, under the namespace, and every host of the tenant serves it.
+	if _, err := store.Get(context.Background(), "partner.page.link", shortCode); err != nil {
+		t.Errorf("link not stored under the namespace: %v", err)
+	}
+	for _, host := range []string{"go.partner.com", "partner.page.link"} {
+		if location := do(http.MethodGet, "https://"+host+"/"+shortCode, "").Header().Get("Location"); location != "https://partner.com/old" {
+			t.Errorf("%s Location = %v, want the created link", host, location)
+		}
+	}
+
+	// An edit through one host reaches the cached redirects of the other.
+	putBody := `{"longLink": "https://partner.page.link/?link=https%3A%2F%2Fpartner.com%2Fpost&ofl=https%3A%2F%2Fpartner.com%2Fnew"}`
+	if rec := do(http.MethodPut, "https://partner.page.link/admin/links/"+shortCode, putBody); rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if location := do(http.MethodGet, "https://go.partner.com/"+shortCode, "").Header().Get("Location"); location != "https://partner.com/new" {
+		t.Errorf("Location after PUT = %v, want the edited link", location)
+	}
+
+	if rec := do(http.MethodDelete, "https://go.partner.com/admin/links/"+shortCode, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := do(http.MethodGet, "https://partner.page.link/"+shortCode, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status after DELETE = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+
+	"dynamic-link-redirect/tracing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceRequests starts the server span of each request as the root of a new trace. The requests
+// come from the internet, so a traceparent passed in is only linked: continuing it would let any
+// client pick the trace and force it to be sampled. The span is named after the route pattern
+// once routing has happened.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var links []trace.Link
+		remote := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header)))
+		if remote.IsValid() {
+			links = append(links, trace.Link{SpanContext: remote})
+		}
+		ctx, span := tracing.Tracer().Start(r.Context(), r.Method,
+			trace.WithNewRoot(),
+			trace.WithLinks(links...),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.ServerAddress(r.Host),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if route := chi.RouteContext(ctx).RoutePattern(); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// traceStage starts a span for one stage of a request; the caller ends it.
+func traceStage(r *http.Request, name string) trace.Span {
+	_, span := tracing.Tracer().Start(r.Context(), name)
+	return span
+}
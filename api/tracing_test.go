@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dynamic-link-redirect/api/model"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	var backendTraceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendTraceparent = r.Header.Get("traceparent")
+		json.NewEncoder(w).Encode(model.LongLinkResponseModel{LongLink: "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost&apn=com.example"})
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{PreviewUrlStyle: "hyphenated"}
+	resolver := service.NewExchangeLinkResolver(service.NewExchangeClient(backend.URL, service.ExchangeClientOptions{}))
+	router := newTestRouterWithResolver(cfg, resolver, service.NewMemoryLinkStore())
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "https://preview-example.page.link/abc", nil)
+	req.Header.Set("User-Agent", testAndroidUA)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the preview page", rec.Code)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server := spans["GET /{shortCode}"]
+	if server == nil {
+		t.Fatalf("no server span among %v", spans)
+	}
+	// The client's traceparent is linked, not continued.
+	ownTraceID := server.SpanContext().TraceID().String()
+	if ownTraceID == traceID || server.Parent().IsValid() {
+		t.Errorf("server span continues the client's trace %s", traceID)
+	}
+	if links := server.Links(); len(links) != 1 || links[0].SpanContext.TraceID().String() != traceID {
+		t.Errorf("server span links = %+v, want the client's trace %s", links, traceID)
+	}
+	for name, span := range spans {
+		if span.SpanContext().TraceID().String() != ownTraceID {
+			t.Errorf("span %q is in trace %s, want %s", name, span.SpanContext().TraceID(), ownTraceID)
+		}
+	}
+	if len(backendTraceparent) != 55 || backendTraceparent[3:35] != ownTraceID {
+		t.Errorf("exchange traceparent = %q, want trace %s", backendTraceparent, ownTraceID)
+	}
+	for _, name := range []string{"GET /{shortCode}", "ClassifyRequest", "IsPreviewHost", "ResolveLink", "ExchangeShortLink", "POST exchangeShortLink", "render preview.html"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("no %q span among %v", name, spans)
+		}
+	}
+	if resolve := spans["ResolveLink"]; resolve != nil &&
+		resolve.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("ResolveLink is not a child of the server span")
+	}
+}
+
+func TestTracingIgnoresClientSampling(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(recorder),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0))),
+	))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	router := newTestRouter(map[string]string{"example.page.link/abc": "https://example.page.link/?link=https%3A%2F%2Fexample.com%2Fpost"})
+	req := httptest.NewRequest(http.MethodGet, "https://example.page.link/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("a sampled traceparent recorded %d spans with a sample ratio of 0", len(spans))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/config"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runImport implements the "import" subcommand, which loads a Firebase Dynamic Links export
+// into the configured link store. Progress is checkpointed after every batch so an interrupted
+// import picks up where it stopped when run again with the same file.
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("file", "", "CSV or JSON export to import (required)")
+	format := flags.String("format", "", "csv or json; detected from the file when empty")
+	checkpointPath := flags.String("checkpoint", "", "checkpoint file (default <file>.checkpoint)")
+	restart := flags.Bool("restart", false, "ignore an existing checkpoint and start from the first row")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dynamic-link-redirect import -file export.csv [flags]")
+		fmt.Fprintln(flags.Output(), "Writes directly to LINK_STORE; stop the server first when it uses the same file store, or use POST /admin/import instead.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *file == "" {
+		flags.Usage()
+		return errors.New("-file is required")
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = *file + ".checkpoint"
+	}
+
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load tenants: %w", err)
+	}
+	store, err := service.NewLinkStore(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create link store: %w", err)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	options := service.ImportOptions{
+		Format: *format,
+		Checkpoint: func(rows int64) error {
+			return writeCheckpoint(*checkpointPath, rows)
+		},
+	}
+	if !*restart {
+		if options.SkipRows, err = readCheckpoint(*checkpointPath); err != nil {
+			return err
+		}
+		if options.SkipRows > 0 {
+			log.Info().Int64("rows", options.SkipRows).Str("checkpoint", *checkpointPath).Msg("Resuming import")
+		}
+	}
+
+	input, err := os.Open(*file)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer input.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, importErr := service.NewLinkImporter(service.NewTenantLinkStore(store, tenants), nil).Import(ctx, input, options)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if importErr != nil {
+		return fmt.Errorf("import stopped, run again to resume: %w", importErr)
+	}
+	return nil
+}
+
+func readCheckpoint(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	rows, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return rows, nil
+}
+
+func writeCheckpoint(path string, rows int64) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(strconv.FormatInt(rows, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
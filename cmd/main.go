@@ -3,11 +3,16 @@ package main
 import (
 	"context"
 	"dynamic-link-redirect/api"
+	"dynamic-link-redirect/api/service"
 	"dynamic-link-redirect/config"
+	"dynamic-link-redirect/tracing"
+	"expvar"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -26,7 +31,114 @@ func main() {
 	}
 
 	cfg := config.New()
-	router := api.NewRouter(cfg)
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(cfg, os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Import failed")
+		}
+		return
+	}
+
+	switch cfg.ExpiredLinkBehavior {
+	case "gone", "ofl":
+	case "fallback":
+		if cfg.ExpiredLinkFallbackURL == "" {
+			log.Fatal().Msg("EXPIRED_LINK_BEHAVIOR=fallback requires EXPIRED_LINK_FALLBACK_URL")
+		}
+	default:
+		log.Fatal().Str("value", cfg.ExpiredLinkBehavior).Msg("Invalid EXPIRED_LINK_BEHAVIOR")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+
+	tenants, err := config.LoadTenants(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load tenants")
+	}
+
+	store, err := service.NewLinkStore(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create link store")
+	}
+
+	resolver, err := service.NewLinkResolver(cfg, tenants, store)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create link resolver")
+	}
+
+	linkService := service.NewDynamicLinkService(cfg, tenants, resolver)
+	expvar.Publish("linkCache", expvar.Func(func() any {
+		stats, _ := linkService.CacheStats()
+		return stats
+	}))
+	registerCacheMetrics(linkService)
+
+	shortLinkService := service.NewShortLinkService(cfg, store, linkService)
+	if (cfg.AdminAPIToken != "" || cfg.ShortLinksAPIKey != "") && cfg.LinkResolver != "store" {
+		log.Warn().Str("resolver", cfg.LinkResolver).Msg("Links created or edited through the API are stored but only redirect with LINK_RESOLVER=store")
+	}
+
+	if cfg.ClipboardHandoff == "true" && cfg.InstallAttribution != "true" {
+		log.Fatal().Msg("CLIPBOARD_HANDOFF requires INSTALL_ATTRIBUTION=true")
+	}
+	if cfg.ClipboardHandoff == "true" && cfg.ShortLinksAPIKey == "" {
+		log.Fatal().Msg("CLIPBOARD_HANDOFF requires SHORT_LINKS_API_KEY")
+	}
+
+	var attributionService *service.InstallAttributionService
+	if cfg.InstallAttribution == "true" {
+		attributionService, err = service.NewInstallAttributionService(cfg, linkService)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create install attribution service")
+		}
+	}
+
+	var linkStats *service.LinkStats
+	var statsSinks []service.ClickEventSink
+	if cfg.LinkStats == "true" {
+		retentionDays, err := strconv.Atoi(cfg.LinkStatsRetentionDays)
+		if err != nil || retentionDays <= 0 {
+			log.Fatal().Str("value", cfg.LinkStatsRetentionDays).Msg("Invalid LINK_STATS_RETENTION_DAYS")
+		}
+		if cfg.ShortLinksAPIKey == "" {
+			log.Fatal().Msg("LINK_STATS requires SHORT_LINKS_API_KEY")
+		}
+		log.Warn().Msg("Link stats are kept in memory: they are lost on restart and each replica only counts its own traffic")
+		linkStats = service.NewLinkStats(retentionDays)
+		statsSinks = append(statsSinks, linkStats)
+	}
+
+	clickEvents, err := service.NewClickEventPipelineFromConfig(cfg, statsSinks...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create click event pipeline")
+	}
+	if clickEvents != nil {
+		expvar.Publish("clickEvents", expvar.Func(func() any {
+			return clickEvents.Stats()
+		}))
+	}
+
+	drainDelay, err := time.ParseDuration(cfg.ShutdownDrainDelay)
+	if err != nil {
+		log.Fatal().Str("value", cfg.ShutdownDrainDelay).Msg("Invalid SHUTDOWN_DRAIN_DELAY")
+	}
+	health := service.NewHealthServiceFromConfig(cfg, tenants, store)
+
+	appLinks, err := service.NewAppLinks(tenants)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to generate app links documents")
+	}
+
+	router := api.NewRouter(cfg, linkService, shortLinkService, api.RouterOptions{
+		Attribution: attributionService,
+		ClickEvents: clickEvents,
+		LinkStats:   linkStats,
+		Health:      health,
+		AppLinks:    appLinks,
+	})
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf("0.0.0.0:%s", cfg.Port),
@@ -36,6 +148,23 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	var adminServer *http.Server
+	if cfg.AdminPort != "" {
+		adminServer = &http.Server{
+			Addr:         fmt.Sprintf("0.0.0.0:%s", cfg.AdminPort),
+			Handler:      api.NewAdminRouter(health),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+		go func() {
+			log.Info().Msgf("Admin server starting on port %s", cfg.AdminPort)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msg("Admin server failed to start")
+			}
+		}()
+	}
+
 	go func() {
 
 		if cfg.SSLEnabled == "true" {
@@ -54,6 +183,17 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+
+	// Fail readiness first and keep serving while the load balancer notices and drains us. A
+	// second signal skips the wait.
+	health.Drain()
+	if drainDelay > 0 {
+		log.Info().Msgf("Draining for %s before shutdown", drainDelay)
+		select {
+		case <-time.After(drainDelay):
+		case <-quit:
+		}
+	}
 	log.Info().Msg("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -62,6 +202,27 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("Admin server forced to shutdown")
+		}
+	}
+
+	if clickEvents != nil {
+		if err := clickEvents.Close(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to flush click events")
+		}
+	}
+
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close link store")
+		}
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
 
 	log.Info().Msg("Server exited properly")
 }
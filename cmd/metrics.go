@@ -0,0 +1,32 @@
+package main
+
+import (
+	"dynamic-link-redirect/api/service"
+	"dynamic-link-redirect/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// registerCacheMetrics exposes the link cache counters, which the cache already keeps for
+// /debug/vars, as Prometheus metrics read at scrape time.
+func registerCacheMetrics(linkService *service.DynamicLinkService) {
+	stat := func(value func(service.CacheStats) float64) func() float64 {
+		return func() float64 {
+			stats, _ := linkService.CacheStats()
+			return value(stats)
+		}
+	}
+	counter := func(name, help string, value func(service.CacheStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: "dynamic_link", Subsystem: "link_cache", Name: name, Help: help}, stat(value))
+	}
+
+	metrics.Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: "dynamic_link", Subsystem: "link_cache", Name: "entries", Help: "Links held in the cache."},
+			stat(func(s service.CacheStats) float64 { return float64(s.Size) })),
+		counter("hits_total", "Resolutions served from a fresh cache entry.", func(s service.CacheStats) float64 { return float64(s.Hits) }),
+		counter("misses_total", "Resolutions that went to the backend.", func(s service.CacheStats) float64 { return float64(s.Misses) }),
+		counter("stale_hits_total", "Resolutions served from a stale entry while it was refreshed.", func(s service.CacheStats) float64 { return float64(s.StaleHits) }),
+		counter("negative_hits_total", "Resolutions served from a cached unknown link.", func(s service.CacheStats) float64 { return float64(s.NegativeHits) }),
+		counter("evictions_total", "Entries evicted to stay within the cache size.", func(s service.CacheStats) float64 { return float64(s.Evictions) }),
+	)
+}
@@ -4,32 +4,162 @@ import (
 	"os"
 )
 
+// The .well-known documents shipped with the service, served while none are generated.
+const (
+	defaultAppleAppSiteAssocFile = "static/apple-app-site-association.json"
+	defaultAssetLinksFile        = "static/assetlinks.json"
+)
+
 type Config struct {
 	Port                      string
+	AdminPort                 string
+	TrustedProxies            string
+	ShutdownDrainDelay        string
+	TenantsFile               string
+	LinkDomains               string
 	PreviewUrlStyle           string
 	ExchangeShortLinkEndpoint string
+	ExchangeTimeout           string
+	ExchangeMaxRetries        string
+	ExchangeRetryBackoff      string
+	ExchangeBreakerThreshold  string
+	ExchangeBreakerCooldown   string
+	LinkResolver              string
+	LinkResolverFile          string
+	LinkStore                 string
+	LinkStorePath             string
+	ShortLinksAPIKey          string
+	AdminAPIToken             string
+	LinkCacheSize             string
+	LinkCacheTTL              string
+	LinkCacheNegativeTTL      string
+	LinkCacheStaleTTL         string
 	AppIconImageURL           string
 	AppName                   string
+	AppleTeamID               string
+	AppleBundleIDs            string
+	AppleAppLinkPaths         string
+	AppleWebCredentials       string
+	AndroidPackageNames       string
+	AndroidSHA256Fingerprints string
+	AppleAppSiteAssocFile     string
+	AssetLinksFile            string
 	SSLEnabled                string
 	SSLCertPath               string
 	SSLKeyPath                string
+	LongLinkAllowedHosts      string
+	ExpiredLinkBehavior       string
+	ExpiredLinkFallbackURL    string
+	DefaultRedirectURL        string
+	InAppBrowserEscape        string
+	InAppBrowserEscapeTimeout string
+	AndroidIntentRedirect     string
+	InstallAttribution        string
+	AttributionWindow         string
+	AttributionMinConfidence  string
+	AttributionMaxClicks      string
+	ClipboardHandoff          string
+	ClipboardTokenSecret      string
+	ClipboardTokenTTL         string
+	ClickEventSinks           string
+	ClickEventBuffer          string
+	ClickEventBatchSize       string
+	ClickEventFlushInterval   string
+	ClickEventFile            string
+	ClickEventFileMaxBytes    string
+	ClickEventFileMaxBackups  string
+	ClickEventWebhookURL      string
+	ClickEventWebhookToken    string
+	CountryHeader             string
+	LinkStats                 string
+	LinkStatsRetentionDays    string
+	TracingExporter           string
+	TracingOTLPEndpoint       string
+	TracingSampleRatio        string
+	TracingServiceName        string
 	EnableFallback            string
 	FallbackHost              string
 }
 
 func New() *Config {
-	return &Config{
+	cfg := &Config{
 		Port:                      getEnv("PORT", "4040"),
+		AdminPort:                 getEnv("ADMIN_PORT", "9090"),              // serves /metrics and /debug/vars, which are never public; empty disables them
+		TrustedProxies:            getEnv("TRUSTED_PROXIES", ""),             // comma separated CIDRs whose X-Forwarded-For is believed; empty trusts none
+		ShutdownDrainDelay:        getEnv("SHUTDOWN_DRAIN_DELAY", "5s"),      // /-/readyz fails this long before the server stops accepting requests
+		TenantsFile:               getEnv("TENANTS_FILE", ""),                // JSON array of per-host app configurations; empty serves every host with the settings below
+		LinkDomains:               getEnv("LINK_DOMAINS", ""),                // comma separated hosts served with the settings below; short links can only be created on these and the tenants' hosts
 		PreviewUrlStyle:           getEnv("PREVIEW_URL_STYLE", "hyphenated"), // hyphenated or subdomain
 		ExchangeShortLinkEndpoint: getEnv("EXCHANGE_SHORT_LINK_ENDPOINT", "http://localhost:9010/v1/exchangeShortLink"),
+		ExchangeTimeout:           getEnv("EXCHANGE_TIMEOUT", "3s"),
+		ExchangeMaxRetries:        getEnv("EXCHANGE_MAX_RETRIES", "2"),
+		ExchangeRetryBackoff:      getEnv("EXCHANGE_RETRY_BACKOFF", "100ms"),
+		ExchangeBreakerThreshold:  getEnv("EXCHANGE_BREAKER_THRESHOLD", "5"), // 0 disables the circuit breaker
+		ExchangeBreakerCooldown:   getEnv("EXCHANGE_BREAKER_COOLDOWN", "30s"),
+		LinkResolver:              getEnv("LINK_RESOLVER", "exchange"), // exchange, file, memory or store
+		LinkResolverFile:          getEnv("LINK_RESOLVER_FILE", "./links.json"),
+		LinkStore:                 getEnv("LINK_STORE", "memory"), // memory or file
+		LinkStorePath:             getEnv("LINK_STORE_PATH", "./links.log"),
+		ShortLinksAPIKey:          getEnv("SHORT_LINKS_API_KEY", ""),  // empty disables POST /v1/shortLinks
+		AdminAPIToken:             getEnv("ADMIN_API_TOKEN", ""),      // empty disables the admin API; its edits only reach redirects with LINK_RESOLVER=store
+		LinkCacheSize:             getEnv("LINK_CACHE_SIZE", "10000"), // 0 disables the cache
+		LinkCacheTTL:              getEnv("LINK_CACHE_TTL", "5m"),
+		LinkCacheNegativeTTL:      getEnv("LINK_CACHE_NEGATIVE_TTL", "30s"),
+		LinkCacheStaleTTL:         getEnv("LINK_CACHE_STALE_TTL", "1h"),
 		AppIconImageURL:           getEnv("APP_ICON_IMAGE_URL", "/static/appIcon.svg"),
 		AppName:                   getEnv("APP_NAME", "My app name"),
+		AppleTeamID:               getEnv("APPLE_TEAM_ID", ""),
+		AppleBundleIDs:            getEnv("APPLE_BUNDLE_IDS", ""),       // comma separated; empty serves no apple-app-site-association
+		AppleAppLinkPaths:         getEnv("APPLE_APP_LINK_PATHS", "/*"), // comma separated path patterns; a leading "!" excludes
+		AppleWebCredentials:       getEnv("APPLE_WEB_CREDENTIALS", "false"),
+		AndroidPackageNames:       getEnv("ANDROID_PACKAGE_NAMES", ""),           // comma separated; empty serves no assetlinks.json
+		AndroidSHA256Fingerprints: getEnv("ANDROID_SHA256_FINGERPRINTS", ""),     // comma separated signing certificate fingerprints
+		AppleAppSiteAssocFile:     getEnv("APPLE_APP_SITE_ASSOCIATION_FILE", ""), // served instead of the generated document when set; defaults to the shipped file without APPLE_BUNDLE_IDS
+		AssetLinksFile:            getEnv("ASSET_LINKS_FILE", ""),                // served instead of the generated document when set; defaults to the shipped file without ANDROID_PACKAGE_NAMES
 		SSLEnabled:                getEnv("SSL_ENABLED", "false"),
 		SSLCertPath:               getEnv("SSL_CERT_PATH", "./tls.crt"),
 		SSLKeyPath:                getEnv("SSL_KEY_PATH", "./tls.key"),
+		LongLinkAllowedHosts:      getEnv("LONG_LINK_ALLOWED_HOSTS", ""),   // comma separated, e.g. "example.com,*.example.com"; empty disables long links at the domain root
+		ExpiredLinkBehavior:       getEnv("EXPIRED_LINK_BEHAVIOR", "gone"), // gone, fallback or ofl
+		ExpiredLinkFallbackURL:    getEnv("EXPIRED_LINK_FALLBACK_URL", ""),
+		DefaultRedirectURL:        getEnv("DEFAULT_REDIRECT_URL", ""),                                                          // empty serves templates/default.html
+		InAppBrowserEscape:        getEnv("IN_APP_BROWSER_ESCAPE", "facebook,instagram,tiktok,snapchat,linkedin,twitter,line"), // "*" for all, empty disables
+		InAppBrowserEscapeTimeout: getEnv("IN_APP_BROWSER_ESCAPE_TIMEOUT", "2s"),                                               // default and family:duration overrides, e.g. "2s,instagram:3s"
+		AndroidIntentRedirect:     getEnv("ANDROID_INTENT_REDIRECT", "false"),                                                  // links can override with intent=1 or intent=0
+		InstallAttribution:        getEnv("INSTALL_ATTRIBUTION", "false"),                                                      // keeps click IPs, user agents and screens in memory and adds click_id to Play Store referrers
+		AttributionWindow:         getEnv("INSTALL_ATTRIBUTION_WINDOW", "1h"),
+		AttributionMinConfidence:  getEnv("INSTALL_ATTRIBUTION_MIN_CONFIDENCE", "0.6"),
+		AttributionMaxClicks:      getEnv("INSTALL_ATTRIBUTION_MAX_CLICKS", "100000"),
+		ClipboardHandoff:          getEnv("CLIPBOARD_HANDOFF", "false"), // requires INSTALL_ATTRIBUTION and SHORT_LINKS_API_KEY
+		ClipboardTokenSecret:      getEnv("CLIPBOARD_TOKEN_SECRET", ""),
+		ClipboardTokenTTL:         getEnv("CLIPBOARD_TOKEN_TTL", "1h"),
+		ClickEventSinks:           getEnv("CLICK_EVENTS_SINKS", ""),       // comma separated: stdout, file, webhook; empty disables click events
+		ClickEventBuffer:          getEnv("CLICK_EVENTS_BUFFER", "10000"), // events queued per sink before new ones are dropped
+		ClickEventBatchSize:       getEnv("CLICK_EVENTS_BATCH_SIZE", "100"),
+		ClickEventFlushInterval:   getEnv("CLICK_EVENTS_FLUSH_INTERVAL", "5s"),
+		ClickEventFile:            getEnv("CLICK_EVENTS_FILE", "./click_events.jsonl"),
+		ClickEventFileMaxBytes:    getEnv("CLICK_EVENTS_FILE_MAX_BYTES", "104857600"), // 0 disables rotation
+		ClickEventFileMaxBackups:  getEnv("CLICK_EVENTS_FILE_MAX_BACKUPS", "5"),
+		ClickEventWebhookURL:      getEnv("CLICK_EVENTS_WEBHOOK_URL", ""),
+		ClickEventWebhookToken:    getEnv("CLICK_EVENTS_WEBHOOK_TOKEN", ""), // sent as a bearer token
+		CountryHeader:             getEnv("COUNTRY_HEADER", ""),             // set by the CDN in front of the service, e.g. CF-IPCountry; empty records no country
+		LinkStats:                 getEnv("LINK_STATS", "false"),            // counts for GET /v1/{shortLink}/linkStats; kept in memory per process, so lost on restart and split across replicas; needs SHORT_LINKS_API_KEY
+		LinkStatsRetentionDays:    getEnv("LINK_STATS_RETENTION_DAYS", "90"),
+		TracingExporter:           getEnv("TRACING_EXPORTER", "none"),  // none, stdout or otlp
+		TracingOTLPEndpoint:       getEnv("TRACING_OTLP_ENDPOINT", ""), // e.g. http://localhost:4318/v1/traces; empty uses OTEL_EXPORTER_OTLP_* variables
+		TracingSampleRatio:        getEnv("TRACING_SAMPLE_RATIO", "1"),
+		TracingServiceName:        getEnv("TRACING_SERVICE_NAME", "dynamic-link-redirect"),
 		EnableFallback:            getEnv("ENABLE_FALLBACK", "false"),
 		FallbackHost:              getEnv("FALLBACK_HOST", ""),
 	}
+	// Deployments that predate the generated documents keep serving the files shipped in static/.
+	if cfg.AppleAppSiteAssocFile == "" && cfg.AppleBundleIDs == "" {
+		cfg.AppleAppSiteAssocFile = defaultAppleAppSiteAssocFile
+	}
+	if cfg.AssetLinksFile == "" && cfg.AndroidPackageNames == "" {
+		cfg.AssetLinksFile = defaultAssetLinksFile
+	}
+	return cfg
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+func TestNewWellKnownFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		wantAASAFile  string
+		wantAssetFile string
+	}{
+		{
+			name:          "shipped files without generated documents",
+			env:           map[string]string{"APPLE_APP_SITE_ASSOCIATION_FILE": "", "ASSET_LINKS_FILE": ""},
+			wantAASAFile:  defaultAppleAppSiteAssocFile,
+			wantAssetFile: defaultAssetLinksFile,
+		},
+		{
+			name: "generated documents",
+			env:  map[string]string{"APPLE_BUNDLE_IDS": "com.example.app", "ANDROID_PACKAGE_NAMES": "com.example.app"},
+		},
+		{
+			name:          "explicit override files",
+			env:           map[string]string{"APPLE_APP_SITE_ASSOCIATION_FILE": "aasa.json", "ASSET_LINKS_FILE": "assetlinks.json"},
+			wantAASAFile:  "aasa.json",
+			wantAssetFile: "assetlinks.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"APPLE_BUNDLE_IDS", "ANDROID_PACKAGE_NAMES", "APPLE_APP_SITE_ASSOCIATION_FILE", "ASSET_LINKS_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+			cfg := New()
+			if cfg.AppleAppSiteAssocFile != tt.wantAASAFile || cfg.AssetLinksFile != tt.wantAssetFile {
+				t.Errorf("files = %q, %q, want %q, %q", cfg.AppleAppSiteAssocFile, cfg.AssetLinksFile, tt.wantAASAFile, tt.wantAssetFile)
+			}
+		})
+	}
+}
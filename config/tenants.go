@@ -0,0 +1,248 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Tenant is the configuration of one app served on its own link domains. Fields left empty in
+// TENANTS_FILE take the value of the environment configuration.
+type Tenant struct {
+	Name string `json:"name"`
+	// Hosts are the link domains of the tenant, without their preview hosts.
+	Hosts                     []string `json:"hosts"`
+	AppName                   string   `json:"appName"`
+	AppIconImageURL           string   `json:"appIconImageUrl"`
+	PreviewUrlStyle           string   `json:"previewUrlStyle"`
+	ExchangeShortLinkEndpoint string   `json:"exchangeShortLinkEndpoint"`
+	// StoreNamespace is the domain under which the link store holds the tenant's links, so that
+	// all of its hosts create, edit and serve the same links. Empty keeps links under the host
+	// they were created for.
+	StoreNamespace string `json:"storeNamespace"`
+	FallbackHost   string `json:"fallbackHost"`
+	// AppStoreID and AndroidPackageName stand in for the isi and apn parameters of links that
+	// have none.
+	AppStoreID         string    `json:"appStoreId"`
+	AndroidPackageName string    `json:"androidPackageName"`
+	AppLinks           *AppLinks `json:"appLinks"`
+	// AppleAppSiteAssociationFile and AssetLinksFile are served instead of the documents
+	// generated from AppLinks when set.
+	AppleAppSiteAssociationFile string `json:"appleAppSiteAssociationFile"`
+	AssetLinksFile              string `json:"assetLinksFile"`
+}
+
+// AppLinks describes the apps allowed to open a tenant's links, from which the
+// apple-app-site-association and assetlinks.json documents are generated.
+type AppLinks struct {
+	AppleTeamID    string   `json:"appleTeamId"`
+	AppleBundleIDs []string `json:"appleBundleIds"`
+	// ApplePaths are the path patterns the iOS apps open, in order; a leading "!" excludes the
+	// paths matching the rest of the pattern. Empty matches every path.
+	ApplePaths                []string `json:"applePaths"`
+	AppleWebCredentials       bool     `json:"appleWebCredentials"`
+	AndroidPackageNames       []string `json:"androidPackageNames"`
+	AndroidSHA256Fingerprints []string `json:"androidSha256CertFingerprints"`
+}
+
+// Tenants selects the tenant of a request by its host. Hosts of no tenant get the default one,
+// built from the environment configuration.
+type Tenants struct {
+	defaultTenant *Tenant
+	tenants       []*Tenant
+	byHost        map[string]*Tenant
+}
+
+// NewTenants returns the single tenant described by the environment configuration, which
+// claims the hosts in LINK_DOMAINS.
+func NewTenants(cfg *Config) *Tenants {
+	t := &Tenants{
+		defaultTenant: &Tenant{
+			Name:                      "default",
+			Hosts:                     splitList(cfg.LinkDomains),
+			AppName:                   cfg.AppName,
+			AppIconImageURL:           cfg.AppIconImageURL,
+			PreviewUrlStyle:           cfg.PreviewUrlStyle,
+			ExchangeShortLinkEndpoint: cfg.ExchangeShortLinkEndpoint,
+			FallbackHost:              strings.TrimSpace(cfg.FallbackHost),
+			AppLinks: &AppLinks{
+				AppleTeamID:               strings.TrimSpace(cfg.AppleTeamID),
+				AppleBundleIDs:            splitList(cfg.AppleBundleIDs),
+				ApplePaths:                splitList(cfg.AppleAppLinkPaths),
+				AppleWebCredentials:       cfg.AppleWebCredentials == "true",
+				AndroidPackageNames:       splitList(cfg.AndroidPackageNames),
+				AndroidSHA256Fingerprints: splitList(cfg.AndroidSHA256Fingerprints),
+			},
+			AppleAppSiteAssociationFile: cfg.AppleAppSiteAssocFile,
+			AssetLinksFile:              cfg.AssetLinksFile,
+		},
+		byHost: make(map[string]*Tenant),
+	}
+	for _, host := range t.defaultTenant.Hosts {
+		t.byHost[normalizeHost(host)] = t.defaultTenant
+	}
+	return t
+}
+
+// LoadTenants reads the JSON array of tenants in TENANTS_FILE, e.g.
+//
+//	[{"name": "partner", "hosts": ["partner.page.link"], "appName": "Partner", "appStoreId": "123456789"}]
+//
+// Without TENANTS_FILE every host is served by the default tenant.
+func LoadTenants(cfg *Config) (*Tenants, error) {
+	tenants := NewTenants(cfg)
+	if cfg.TenantsFile == "" {
+		return tenants, nil
+	}
+
+	data, err := os.ReadFile(cfg.TenantsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	var configured []Tenant
+	if err := json.Unmarshal(data, &configured); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tenants file: %w", err)
+	}
+
+	for i := range configured {
+		if err := tenants.add(&configured[i]); err != nil {
+			return nil, err
+		}
+	}
+	return tenants, nil
+}
+
+func (t *Tenants) add(tenant *Tenant) error {
+	if tenant.Name == "" {
+		return fmt.Errorf("tenant without a name")
+	}
+	if len(tenant.Hosts) == 0 {
+		return fmt.Errorf("tenant %s has no hosts", tenant.Name)
+	}
+	switch tenant.PreviewUrlStyle {
+	case "", "hyphenated", "subdomain":
+	default:
+		return fmt.Errorf("tenant %s has invalid preview URL style: %s", tenant.Name, tenant.PreviewUrlStyle)
+	}
+
+	defaults := t.defaultTenant
+	inherit := func(value *string, defaultValue string) {
+		if *value == "" {
+			*value = defaultValue
+		}
+	}
+	inherit(&tenant.AppName, defaults.AppName)
+	inherit(&tenant.AppIconImageURL, defaults.AppIconImageURL)
+	inherit(&tenant.PreviewUrlStyle, defaults.PreviewUrlStyle)
+	inherit(&tenant.ExchangeShortLinkEndpoint, defaults.ExchangeShortLinkEndpoint)
+	inherit(&tenant.FallbackHost, defaults.FallbackHost)
+	// A tenant with app links of its own does not get the default override files, which would
+	// hide its generated documents.
+	if tenant.AppLinks == nil {
+		tenant.AppLinks = defaults.AppLinks
+		inherit(&tenant.AppleAppSiteAssociationFile, defaults.AppleAppSiteAssociationFile)
+		inherit(&tenant.AssetLinksFile, defaults.AssetLinksFile)
+	}
+
+	for _, host := range tenant.Hosts {
+		host = normalizeHost(host)
+		if other, ok := t.byHost[host]; ok {
+			return fmt.Errorf("host %s belongs to tenants %s and %s", host, other.Name, tenant.Name)
+		}
+		t.byHost[host] = tenant
+	}
+	t.tenants = append(t.tenants, tenant)
+	return nil
+}
+
+// ForHost returns the tenant serving host, which may be one of its preview hosts.
+func (t *Tenants) ForHost(host string) *Tenant {
+	host = normalizeHost(host)
+	if tenant, ok := t.byHost[host]; ok {
+		return tenant
+	}
+
+	label, rest, _ := strings.Cut(host, ".")
+	if trimmed, ok := strings.CutPrefix(label, "preview-"); ok {
+		if tenant, ok := t.byHost[trimmed+"."+rest]; ok && tenant.PreviewUrlStyle == "hyphenated" {
+			return tenant
+		}
+	}
+	if label == "preview" {
+		if tenant, ok := t.byHost[rest]; ok && tenant.PreviewUrlStyle == "subdomain" {
+			return tenant
+		}
+	}
+	return t.defaultTenant
+}
+
+// Serves reports whether host is one of the link domains of a tenant, not counting preview hosts.
+func (t *Tenants) Serves(host string) bool {
+	_, ok := t.byHost[normalizeHost(host)]
+	return ok
+}
+
+// StoreDomain returns the domain under which the link store holds the links of host: the store
+// namespace of its tenant, or host itself.
+func (t *Tenants) StoreDomain(host string) string {
+	if namespace := t.ForHost(host).StoreNamespace; namespace != "" {
+		return namespace
+	}
+	return host
+}
+
+// StoreHosts returns domain and every link domain whose links the store holds under the same
+// domain as those of domain.
+func (t *Tenants) StoreHosts(domain string) []string {
+	hosts := []string{domain}
+	storeDomain := t.StoreDomain(domain)
+	for host := range t.byHost {
+		if !strings.EqualFold(host, domain) && strings.EqualFold(t.StoreDomain(host), storeDomain) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Default returns the tenant of hosts no configured tenant claims.
+func (t *Tenants) Default() *Tenant {
+	return t.defaultTenant
+}
+
+// All returns the default tenant followed by the configured ones.
+func (t *Tenants) All() []*Tenant {
+	return append([]*Tenant{t.defaultTenant}, t.tenants...)
+}
+
+// FillStoreIDs sets the isi and apn parameters the link lacks to the tenant's store IDs.
+func (t *Tenant) FillStoreIDs(queryParams url.Values) {
+	if t.AppStoreID != "" && queryParams.Get("isi") == "" {
+		queryParams.Set("isi", t.AppStoreID)
+	}
+	if t.AndroidPackageName != "" && queryParams.Get("apn") == "" {
+		queryParams.Set("apn", t.AndroidPackageName)
+	}
+}
+
+// splitList splits a comma separated setting, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// normalizeHost lowercases host and drops its port.
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return hostname
+	}
+	return host
+}
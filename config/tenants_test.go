@@ -0,0 +1,111 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadTenants(t *testing.T) {
+	cfg := &Config{
+		AppName:                   "Default App",
+		LinkDomains:               "links.example.com",
+		AppIconImageURL:           "/static/appIcon.svg",
+		PreviewUrlStyle:           "hyphenated",
+		ExchangeShortLinkEndpoint: "http://localhost:9010/v1/exchangeShortLink",
+		AndroidPackageNames:       "com.example, com.example.lite",
+		AssetLinksFile:            "override/assetlinks.json",
+		TenantsFile:               filepath.Join(t.TempDir(), "tenants.json"),
+	}
+	err := os.WriteFile(cfg.TenantsFile, []byte(`[
+		{"name": "partner", "hosts": ["Partner.page.link", "go.partner.com"], "appName": "Partner", "previewUrlStyle": "subdomain", "appStoreId": "123", "androidPackageName": "com.partner",
+			"appLinks": {"androidPackageNames": ["com.partner"]}},
+		{"name": "other", "hosts": ["other.page.link"], "exchangeShortLinkEndpoint": "http://other/v1/exchangeShortLink"}
+	]`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenants, err := LoadTenants(cfg)
+	if err != nil {
+		t.Fatalf("LoadTenants() error = %v", err)
+	}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "partner.page.link", want: "partner"},
+		{host: "go.partner.com:443", want: "partner"},
+		{host: "preview.partner.page.link", want: "partner"},
+		// The partner tenant uses subdomain preview hosts, so this is not one of them.
+		{host: "preview-partner.page.link", want: "default"},
+		{host: "preview-other.page.link", want: "other"},
+		{host: "unknown.page.link", want: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := tenants.ForHost(tt.host).Name; got != tt.want {
+				t.Errorf("ForHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+
+	other := tenants.ForHost("other.page.link")
+	if other.AppName != "Default App" || other.PreviewUrlStyle != "hyphenated" || other.AssetLinksFile != "override/assetlinks.json" {
+		t.Errorf("other tenant did not inherit the defaults: %+v", other)
+	}
+	if !reflect.DeepEqual(other.AppLinks.AndroidPackageNames, []string{"com.example", "com.example.lite"}) {
+		t.Errorf("other package names = %v", other.AppLinks.AndroidPackageNames)
+	}
+	if partner := tenants.ForHost("partner.page.link"); partner.AssetLinksFile != "" {
+		t.Errorf("partner asset links file = %q, want its generated document", partner.AssetLinksFile)
+	}
+	if other.ExchangeShortLinkEndpoint != "http://other/v1/exchangeShortLink" {
+		t.Errorf("other endpoint = %q", other.ExchangeShortLinkEndpoint)
+	}
+	if len(tenants.All()) != 3 {
+		t.Errorf("All() returned %d tenants, want 3", len(tenants.All()))
+	}
+	for host, want := range map[string]bool{"go.partner.com": true, "links.example.com": true, "preview-other.page.link": false, "evil.example": false} {
+		if got := tenants.Serves(host); got != want {
+			t.Errorf("Serves(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	params := url.Values{"link": {"https://example.com"}, "apn": {"com.example"}}
+	tenants.ForHost("partner.page.link").FillStoreIDs(params)
+	if params.Get("isi") != "123" || params.Get("apn") != "com.example" {
+		t.Errorf("FillStoreIDs() = %v, want isi filled in and apn kept", params)
+	}
+}
+
+func TestLoadTenantsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "not json", content: `{`},
+		{name: "no name", content: `[{"hosts": ["a.page.link"]}]`},
+		{name: "no hosts", content: `[{"name": "a"}]`},
+		{name: "invalid preview style", content: `[{"name": "a", "hosts": ["a.page.link"], "previewUrlStyle": "suffix"}]`},
+		{name: "shared host", content: `[{"name": "a", "hosts": ["a.page.link"]}, {"name": "b", "hosts": ["A.page.link"]}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{TenantsFile: filepath.Join(t.TempDir(), "tenants.json")}
+			if err := os.WriteFile(cfg.TenantsFile, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadTenants(cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := LoadTenants(&Config{TenantsFile: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
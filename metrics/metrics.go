@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dynamic_link"
+
+// Registry holds every metric of the service. It is served on the admin port only.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	Redirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Dynamic link requests by platform, decision and status code.",
+	}, []string{"platform", "decision", "status"})
+
+	RedirectDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redirect_duration_seconds",
+		Help:      "Time to answer a dynamic link request, including the link exchange.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"platform", "decision"})
+
+	ExchangeRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exchange_requests_total",
+		Help:      "Calls to the exchangeShortLink backend by result: ok, not_found, error, unavailable or canceled.",
+	}, []string{"result"})
+
+	ExchangeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "exchange_duration_seconds",
+		Help:      "Time spent in exchangeShortLink calls, including retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	TemplateErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "template_errors_total",
+		Help:      "Templates that failed to parse or render, by template.",
+	}, []string{"template"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		Redirects,
+		RedirectDuration,
+		ExchangeRequests,
+		ExchangeDuration,
+		TemplateErrors,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"dynamic-link-redirect/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "dynamic-link-redirect"
+
+// Tracer returns the service's tracer. It is a no-op until Setup installs an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the W3C trace context propagator and, unless TRACING_EXPORTER is "none", a
+// tracer provider exporting to stdout or OTLP over HTTP. The returned function flushes pending
+// spans and stops the exporter.
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.TracingExporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		var options []otlptracehttp.Option
+		if cfg.TracingOTLPEndpoint != "" {
+			// Empty leaves the endpoint to the OTEL_EXPORTER_OTLP_* environment variables.
+			options = append(options, otlptracehttp.WithEndpointURL(cfg.TracingOTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter: %s", cfg.TracingExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.TracingExporter, err)
+	}
+
+	ratio, err := strconv.ParseFloat(cfg.TracingSampleRatio, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		exporter.Shutdown(ctx)
+		return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO %q", cfg.TracingSampleRatio)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(cfg.TracingServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		exporter.Shutdown(ctx)
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Server spans are roots, so the ratio applies to every request.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
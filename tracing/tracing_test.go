@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"dynamic-link-redirect/config"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{"disabled", config.Config{TracingExporter: "none"}, false},
+		{"unset", config.Config{}, false},
+		{"unknown exporter", config.Config{TracingExporter: "jaeger"}, true},
+		{"invalid sample ratio", config.Config{TracingExporter: "stdout", TracingSampleRatio: "2"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), &tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() error = %v", err)
+				}
+			}
+		})
+	}
+}
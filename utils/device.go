@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type OS string
+
+const (
+	OSUnknown  OS = ""
+	OSiOS      OS = "ios"
+	OSAndroid  OS = "android"
+	OSMacOS    OS = "macos"
+	OSWindows  OS = "windows"
+	OSLinux    OS = "linux"
+	OSChromeOS OS = "chromeos"
+)
+
+type DeviceClass string
+
+const (
+	DeviceUnknown DeviceClass = ""
+	DevicePhone   DeviceClass = "phone"
+	DeviceTablet  DeviceClass = "tablet"
+	DeviceDesktop DeviceClass = "desktop"
+	DeviceBot     DeviceClass = "bot"
+)
+
+// PlatformHintParam is set by the detection page when the User-Agent alone cannot tell an iPad
+// requesting the desktop site from a Mac. Its only recognized values are "ipados" and "macos".
+const PlatformHintParam = "platform-hint"
+
+// Device is what the redirect flow needs to know about the client behind a request.
+type Device struct {
+	OS        OS
+	OSVersion string
+	Class     DeviceClass
+	// Browser is the rendering browser, e.g. "safari", "chrome" or "webview" for embedded views.
+	Browser string
+	// InAppBrowser names the app whose embedded browser made the request, e.g. "instagram".
+	InAppBrowser string
+	// MaybeIPad is set for desktop Safari on a Mac, which is also what iPadOS sends by default.
+	MaybeIPad bool
+}
+
+func (d Device) IsIOS() bool {
+	return d.OS == OSiOS
+}
+
+func (d Device) IsIPad() bool {
+	return d.OS == OSiOS && d.Class == DeviceTablet
+}
+
+func (d Device) IsAndroid() bool {
+	return d.OS == OSAndroid
+}
+
+func (d Device) IsBot() bool {
+	return d.Class == DeviceBot
+}
+
+var (
+	iOSVersionPattern      = regexp.MustCompile(`OS (\d+(?:_\d+)*) like Mac OS X`)
+	androidVersionPattern  = regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)
+	macOSVersionPattern    = regexp.MustCompile(`Mac OS X (\d+(?:[_.]\d+)*)`)
+	windowsVersionPattern  = regexp.MustCompile(`Windows NT (\d+(?:\.\d+)*)`)
+	chromeOSVersionPattern = regexp.MustCompile(`CrOS \S+ (\d+(?:\.\d+)*)`)
+)
+
+// botTokens are matched case-insensitively. "bot" alone would also match handset names such as
+// Cubot, so it only counts when followed by a separator. Link unfurlers are included because they
+// fetch links the same way crawlers do.
+var botTokens = []string{
+	"bot/", "bot-", "bot;", "+http", "crawler", "spider", "slurp", "facebookexternalhit",
+	"facebookcatalog", "whatsapp", "embedly", "quora link preview", "vkshare", "pinterest/0.", "skypeuripreview", "telegrambot",
+	"curl/", "wget/",
+}
+
+// inAppBrowserTokens map User-Agent tokens to the app embedding the browser. Order matters where
+// one app's token contains another's.
+var inAppBrowserTokens = []struct {
+	token string
+	app   string
+}{
+	{"FBAN/", "facebook"},
+	{"FBAV/", "facebook"},
+	{"FB_IAB/", "facebook"},
+	{"Instagram", "instagram"},
+	{"musical_ly", "tiktok"},
+	{"BytedanceWebview", "tiktok"},
+	{"TikTok", "tiktok"},
+	{"Snapchat", "snapchat"},
+	{"LinkedInApp", "linkedin"},
+	{"Twitter", "twitter"},
+	{"Pinterest", "pinterest"},
+	{"MicroMessenger", "wechat"},
+	{"Line/", "line"},
+	{"GSA/", "google"},
+	{"Telegram", "telegram"},
+}
+
+// ClassifyUserAgent derives the device from a User-Agent string alone.
+func ClassifyUserAgent(userAgent string) Device {
+	device := Device{}
+	lower := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(userAgent, "iPad"):
+		device.OS, device.Class = OSiOS, DeviceTablet
+		device.OSVersion = dottedVersion(iOSVersionPattern, userAgent)
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPod"):
+		device.OS, device.Class = OSiOS, DevicePhone
+		device.OSVersion = dottedVersion(iOSVersionPattern, userAgent)
+	case strings.Contains(userAgent, "Android"):
+		device.OS, device.Class = OSAndroid, DevicePhone
+		// Android tablets omit "Mobile" from the User-Agent.
+		if !strings.Contains(userAgent, "Mobile") {
+			device.Class = DeviceTablet
+		}
+		device.OSVersion = dottedVersion(androidVersionPattern, userAgent)
+	case strings.Contains(userAgent, "CrOS"):
+		device.OS, device.Class = OSChromeOS, DeviceDesktop
+		device.OSVersion = dottedVersion(chromeOSVersionPattern, userAgent)
+	case strings.Contains(userAgent, "Macintosh"):
+		device.OS, device.Class = OSMacOS, DeviceDesktop
+		device.OSVersion = dottedVersion(macOSVersionPattern, userAgent)
+	case strings.Contains(userAgent, "Windows"):
+		device.OS, device.Class = OSWindows, DeviceDesktop
+		device.OSVersion = dottedVersion(windowsVersionPattern, userAgent)
+	case strings.Contains(userAgent, "Linux") || strings.Contains(userAgent, "X11"):
+		device.OS, device.Class = OSLinux, DeviceDesktop
+	}
+
+	device.Browser = classifyBrowser(userAgent, device.OS)
+
+	for _, token := range botTokens {
+		if strings.Contains(lower, token) {
+			device.Class = DeviceBot
+			return device
+		}
+	}
+
+	for _, candidate := range inAppBrowserTokens {
+		if strings.Contains(userAgent, candidate.token) {
+			device.InAppBrowser = candidate.app
+			break
+		}
+	}
+
+	device.MaybeIPad = device.OS == OSMacOS && device.Browser == "safari"
+	return device
+}
+
+// ClassifyRequest classifies the request's User-Agent and applies the platform hint left by the
+// detection page, so iPadOS requesting the desktop site is routed like an iPad.
+func ClassifyRequest(r *http.Request) Device {
+	device := ClassifyUserAgent(r.Header.Get("User-Agent"))
+	if !device.MaybeIPad {
+		return device
+	}
+
+	switch r.URL.Query().Get(PlatformHintParam) {
+	case "ipados":
+		device.OS, device.Class, device.MaybeIPad = OSiOS, DeviceTablet, false
+		// iPadOS reports the Safari version in desktop mode, which tracks the OS version.
+		device.OSVersion = ""
+	case "macos":
+		device.MaybeIPad = false
+	}
+	return device
+}
+
+func classifyBrowser(userAgent string, os OS) string {
+	switch {
+	case strings.Contains(userAgent, "CriOS/"):
+		return "chrome"
+	case strings.Contains(userAgent, "FxiOS/"):
+		return "firefox"
+	case strings.Contains(userAgent, "EdgiOS/") || strings.Contains(userAgent, "EdgA/") || strings.Contains(userAgent, "Edg/"):
+		return "edge"
+	case strings.Contains(userAgent, "SamsungBrowser/"):
+		return "samsung"
+	case strings.Contains(userAgent, "OPR/") || strings.Contains(userAgent, "OPiOS/"):
+		return "opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		return "firefox"
+	case os == OSAndroid && strings.Contains(userAgent, "; wv)"):
+		return "webview"
+	case strings.Contains(userAgent, "Chrome/"):
+		return "chrome"
+	case strings.Contains(userAgent, "Version/") && strings.Contains(userAgent, "Safari/"):
+		return "safari"
+	case os == OSiOS && strings.Contains(userAgent, "AppleWebKit/"):
+		// iOS apps embedding WKWebView leave out the Safari tokens.
+		return "webview"
+	}
+	return ""
+}
+
+func dottedVersion(pattern *regexp.Regexp, userAgent string) string {
+	match := pattern.FindStringSubmatch(userAgent)
+	if match == nil {
+		return ""
+	}
+	return strings.ReplaceAll(match[1], "_", ".")
+}
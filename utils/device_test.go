@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClassifyUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  Device
+	}{
+		{
+			name:      "iPhone Safari",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			expected:  Device{OS: OSiOS, OSVersion: "17.4", Class: DevicePhone, Browser: "safari"},
+		},
+		{
+			name:      "iPhone Chrome",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_6_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			expected:  Device{OS: OSiOS, OSVersion: "16.6.1", Class: DevicePhone, Browser: "chrome"},
+		},
+		{
+			name:      "iPad Safari",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			expected:  Device{OS: OSiOS, OSVersion: "17.4", Class: DeviceTablet, Browser: "safari"},
+		},
+		{
+			name:      "iPadOS desktop mode Safari",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			expected:  Device{OS: OSMacOS, OSVersion: "10.15.7", Class: DeviceDesktop, Browser: "safari", MaybeIPad: true},
+		},
+		{
+			name:      "Mac Chrome",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			expected:  Device{OS: OSMacOS, OSVersion: "10.15.7", Class: DeviceDesktop, Browser: "chrome"},
+		},
+		{
+			name:      "Android phone Chrome",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			expected:  Device{OS: OSAndroid, OSVersion: "14", Class: DevicePhone, Browser: "chrome"},
+		},
+		{
+			name:      "Android tablet Chrome",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expected:  Device{OS: OSAndroid, OSVersion: "13", Class: DeviceTablet, Browser: "chrome"},
+		},
+		{
+			name:      "Samsung Internet",
+			userAgent: "Mozilla/5.0 (Linux; Android 10; SAMSUNG SM-G973F) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			expected:  Device{OS: OSAndroid, OSVersion: "10", Class: DevicePhone, Browser: "samsung"},
+		},
+		{
+			name:      "Android Firefox",
+			userAgent: "Mozilla/5.0 (Android 14; Mobile; rv:125.0) Gecko/125.0 Firefox/125.0",
+			expected:  Device{OS: OSAndroid, OSVersion: "14", Class: DevicePhone, Browser: "firefox"},
+		},
+		{
+			name:      "Android handset named like a bot",
+			userAgent: "Mozilla/5.0 (Linux; Android 11; CUBOT X30) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			expected:  Device{OS: OSAndroid, OSVersion: "11", Class: DevicePhone, Browser: "chrome"},
+		},
+		{
+			name:      "Chromebook",
+			userAgent: "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			expected:  Device{OS: OSChromeOS, OSVersion: "14541.0.0", Class: DeviceDesktop, Browser: "chrome"},
+		},
+		{
+			name:      "Windows Edge",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			expected:  Device{OS: OSWindows, OSVersion: "10.0", Class: DeviceDesktop, Browser: "edge"},
+		},
+		{
+			name:      "Linux Firefox",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			expected:  Device{OS: OSLinux, Class: DeviceDesktop, Browser: "firefox"},
+		},
+		{
+			name:      "Instagram on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_3_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 321.0.2.24.105 (iPhone15,2; iOS 17_3_1; en_US; en; scale=3.00; 1179x2556; 566948718)",
+			expected:  Device{OS: OSiOS, OSVersion: "17.3.1", Class: DevicePhone, Browser: "webview", InAppBrowser: "instagram"},
+		},
+		{
+			name:      "Facebook on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBAV/445.0.0.35.117;FBBV/553483340;FBDV/iPhone14,5;FBMD/iPhone;FBSN/iOS;FBSV/17.1;FBSS/3;FBCR/;FBID/phone;FBLC/en_US;FBOP/80]",
+			expected:  Device{OS: OSiOS, OSVersion: "17.1", Class: DevicePhone, Browser: "webview", InAppBrowser: "facebook"},
+		},
+		{
+			name:      "Facebook on Android",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-S908B Build/TP1A.220624.014; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.43 Mobile Safari/537.36 [FB_IAB/FB4A;FBAV/445.0.0.34.118;]",
+			expected:  Device{OS: OSAndroid, OSVersion: "13", Class: DevicePhone, Browser: "webview", InAppBrowser: "facebook"},
+		},
+		{
+			name:      "TikTok on Android",
+			userAgent: "Mozilla/5.0 (Linux; Android 12; SM-A525F Build/SP1A.210812.016; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/119.0.6045.193 Mobile Safari/537.36 trill_320403 JsSdk/1.0 NetType/WIFI Channel/googleplay AppName/musical_ly app_version/32.4.3 ByteLocale/en ByteFullLocale/en Region/US BytedanceWebview/d8a21c6",
+			expected:  Device{OS: OSAndroid, OSVersion: "12", Class: DevicePhone, Browser: "webview", InAppBrowser: "tiktok"},
+		},
+		{
+			name:      "Google app on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) GSA/309.0.620611655 Mobile/15E148 Safari/604.1",
+			expected:  Device{OS: OSiOS, OSVersion: "17.4", Class: DevicePhone, Browser: "webview", InAppBrowser: "google"},
+		},
+		{
+			name:      "Googlebot smartphone",
+			userAgent: "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.201 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected:  Device{OS: OSAndroid, OSVersion: "6.0.1", Class: DeviceBot, Browser: "chrome"},
+		},
+		{
+			name:      "Facebook link preview",
+			userAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			expected:  Device{Class: DeviceBot},
+		},
+		{
+			name:      "Slack link preview",
+			userAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			expected:  Device{Class: DeviceBot},
+		},
+		{
+			name:      "WhatsApp link preview",
+			userAgent: "WhatsApp/2.23.20.0 A",
+			expected:  Device{Class: DeviceBot},
+		},
+		{
+			name:      "iMessage link preview",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_1) AppleWebKit/601.2.4 (KHTML, like Gecko) Version/9.0.1 Safari/601.2.4 facebookexternalhit/1.1 Facebot Twitterbot/1.0",
+			expected:  Device{OS: OSMacOS, OSVersion: "10.11.1", Class: DeviceBot, Browser: "safari"},
+		},
+		{
+			name:      "Twitter link preview",
+			userAgent: "Twitterbot/1.0",
+			expected:  Device{Class: DeviceBot},
+		},
+		{
+			name:      "Discord link preview",
+			userAgent: "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)",
+			expected:  Device{Class: DeviceBot},
+		},
+		{
+			name:      "Telegram link preview",
+			userAgent: "TelegramBot (like TwitterBot)",
+			expected:  Device{Class: DeviceBot},
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/8.5.0",
+			expected:  Device{Class: DeviceBot},
+		},
+		{
+			name:      "empty",
+			userAgent: "",
+			expected:  Device{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyUserAgent(tt.userAgent)
+			if got != tt.expected {
+				t.Errorf("ClassifyUserAgent() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClassifyRequestPlatformHint(t *testing.T) {
+	const macSafariUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+	const macChromeUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+	tests := []struct {
+		name      string
+		userAgent string
+		hint      string
+		expected  Device
+	}{
+		{
+			name:      "ipados hint",
+			userAgent: macSafariUA,
+			hint:      "ipados",
+			expected:  Device{OS: OSiOS, Class: DeviceTablet, Browser: "safari"},
+		},
+		{
+			name:      "macos hint",
+			userAgent: macSafariUA,
+			hint:      "macos",
+			expected:  Device{OS: OSMacOS, OSVersion: "10.15.7", Class: DeviceDesktop, Browser: "safari"},
+		},
+		{
+			name:      "no hint",
+			userAgent: macSafariUA,
+			expected:  Device{OS: OSMacOS, OSVersion: "10.15.7", Class: DeviceDesktop, Browser: "safari", MaybeIPad: true},
+		},
+		{
+			name:      "hint ignored for unambiguous user agent",
+			userAgent: macChromeUA,
+			hint:      "ipados",
+			expected:  Device{OS: OSMacOS, OSVersion: "10.15.7", Class: DeviceDesktop, Browser: "chrome"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "https://example.page.link/abc?"+PlatformHintParam+"="+tt.hint, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			got := ClassifyRequest(req)
+			if got != tt.expected {
+				t.Errorf("ClassifyRequest() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}